@@ -14,7 +14,20 @@ type Binding interface {
 	Bind(*http.Request, any) error
 }
 
+// BindingUri adds BindUri method to Binding. BindUri is similar with Bind,
+// but it reads the Params matched by the router.
+type BindingUri interface {
+	Name() string
+	BindUri(map[string][]string, any) error
+}
+
 var (
-	JSON = jsonBinding{}
-	XML  = xmlBinding{}
+	JSON          = jsonBinding{}
+	XML           = xmlBinding{}
+	Uri           = uriBinding{}
+	Query         = queryBinding{}
+	Form          = formBinding{}
+	FormPost      = formPostBinding{}
+	FormMultipart = formMultipartBinding{}
+	Header        = headerBinding{}
 )
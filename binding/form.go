@@ -0,0 +1,74 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"errors"
+	"net/http"
+)
+
+// defaultMemory is the max memory of the multipart form, the rest of files are stored in temporary files
+const defaultMemory = 32 << 20 // 32M
+
+type formBinding struct {
+}
+
+type formPostBinding struct {
+}
+
+type formMultipartBinding struct {
+}
+
+func (formBinding) Name() string {
+	return "form"
+}
+
+// Bind map the query of url and the form of body to the fields by the "form" tag,
+// the body of "multipart/form-data" is parsed with its files
+func (formBinding) Bind(r *http.Request, obj any) error {
+	if err := r.ParseMultipartForm(defaultMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return err
+	}
+	source := formSource{values: r.Form}
+	if r.MultipartForm != nil {
+		source.files = r.MultipartForm.File
+	}
+	if err := mapFormSource(obj, source, "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+func (formPostBinding) Name() string {
+	return "form-urlencoded"
+}
+
+// Bind map the form of body to the fields by the "form" tag, the query of url is ignored
+func (formPostBinding) Bind(r *http.Request, obj any) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	if err := mapForm(obj, r.PostForm, "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
+
+func (formMultipartBinding) Name() string {
+	return "multipart/form-data"
+}
+
+// Bind map the multipart form of body to the fields by the "form" tag,
+// the files are set to the fields of *multipart.FileHeader and []*multipart.FileHeader
+func (formMultipartBinding) Bind(r *http.Request, obj any) error {
+	if err := r.ParseMultipartForm(defaultMemory); err != nil {
+		return err
+	}
+	source := formSource{values: r.MultipartForm.Value, files: r.MultipartForm.File}
+	if err := mapFormSource(obj, source, "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
@@ -0,0 +1,371 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import (
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/textproto"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errUnknownType = errors.New("unknown type")
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	durationType   = reflect.TypeOf(time.Duration(0))
+	fileHeaderType = reflect.TypeOf((*multipart.FileHeader)(nil))
+)
+
+// formSource is the values and files mapped to the struct
+type formSource struct {
+	values map[string][]string
+	files  map[string][]*multipart.FileHeader
+	// header means the names are the keys of http.Header, they are canonicalized before lookup
+	header bool
+}
+
+// mapForm set the values of form into the struct which ptr point to
+// the key of form is the tag of the field, if the tag is not set use the field's name
+func mapForm(ptr any, form map[string][]string, tag string) error {
+	return mapFormSource(ptr, formSource{values: form}, tag)
+}
+
+// mapFormSource set the values and files of source into the struct which ptr point to
+func mapFormSource(ptr any, source formSource, tag string) error {
+	value := reflect.ValueOf(ptr)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return errors.New("This argumet must have a pointer type")
+	}
+	value = value.Elem()
+	if value.Kind() != reflect.Struct {
+		return errors.New("This argumet must point to a struct")
+	}
+	_, err := mapStruct(value, source, tag, "", nil)
+	return err
+}
+
+// mapStruct walk the fields of struct and set the value by the tag, the names of fields are prefixed by prefix,
+// parents are the types of structs walking. it returns true if any field is set
+//
+//	type Filter struct {
+//	    Page                                    // no tag, the fields are mapped by the same form like page=1&size=10
+//	    Author Author            `form:"author"` // tagged struct, the fields are prefixed like author.name=vex
+//	    Tags   []string          `form:"tags"`   // tags=a&tags=b
+//	    Sort   map[string]string `form:"sort"`   // sort[name]=asc&sort[id]=desc
+//	    Since  time.Time         `form:"since" time_format:"2006-01-02" time_location:"Asia/Shanghai"`
+//	}
+func mapStruct(value reflect.Value, source formSource, tag string, prefix string, parents []reflect.Type) (bool, error) {
+	tp := value.Type()
+	parents = append(parents, tp)
+	set := false
+	for i := 0; i < tp.NumField(); i++ {
+		field := tp.Field(i)
+		// the embedded struct of unexported type is walked for its exported fields
+		if !field.IsExported() && !(field.Anonymous && field.Type.Kind() == reflect.Struct) {
+			continue
+		}
+		name := field.Tag.Get(tag)
+		if name == "-" {
+			continue
+		}
+		fieldValue := value.Field(i)
+		if isStruct(field.Type) {
+			// the struct without tag is a nested struct, set its fields by the same form,
+			// the tagged struct set its fields by the names prefixed by the tag and "."
+			nestedPrefix := prefix
+			if name != "" {
+				nestedPrefix = prefix + name + "."
+			}
+			// the struct pointing to itself like a tree is walked only by the names in the form,
+			// the prefix grows with the tagged one, and the untagged one is not walked again inside itself
+			if !source.hasPrefix(nestedPrefix) || name == "" && field.Type.Kind() == reflect.Pointer && walking(parents, field.Type.Elem()) {
+				continue
+			}
+			ok, err := mapNested(fieldValue, source, tag, nestedPrefix, parents)
+			if err != nil {
+				return false, err
+			}
+			set = set || ok
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		name = prefix + name
+		ok, err := mapField(fieldValue, field, source, name)
+		if err != nil {
+			return false, fmt.Errorf("field [%s] bind failed: %w", name, err)
+		}
+		set = set || ok
+	}
+	return set, nil
+}
+
+// isStruct reports whether the field is a struct or a pointer to struct mapped by its fields,
+// time.Time is mapped as a value
+func isStruct(tp reflect.Type) bool {
+	if tp.Kind() == reflect.Pointer {
+		tp = tp.Elem()
+	}
+	return tp.Kind() == reflect.Struct && tp != timeType && tp != fileHeaderType.Elem()
+}
+
+// walking reports whether the struct type is one of parents
+func walking(parents []reflect.Type, tp reflect.Type) bool {
+	for _, parent := range parents {
+		if parent == tp {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPrefix reports whether any name of values or files starts with prefix
+func (source formSource) hasPrefix(prefix string) bool {
+	if prefix == "" {
+		return len(source.values) > 0 || len(source.files) > 0
+	}
+	if source.header {
+		prefix = textproto.CanonicalMIMEHeaderKey(prefix)
+	}
+	for name := range source.values {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	for name := range source.files {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// mapNested set the fields of the nested struct, the nil pointer is allocated only if any of its fields is set
+func mapNested(value reflect.Value, source formSource, tag string, prefix string, parents []reflect.Type) (bool, error) {
+	if value.Kind() != reflect.Pointer {
+		return mapStruct(value, source, tag, prefix, parents)
+	}
+	if !value.IsNil() {
+		return mapStruct(value.Elem(), source, tag, prefix, parents)
+	}
+	elem := reflect.New(value.Type().Elem())
+	ok, err := mapStruct(elem.Elem(), source, tag, prefix, parents)
+	if ok && err == nil {
+		value.Set(elem)
+	}
+	return ok, err
+}
+
+// mapField set the field by the values or files of the name, it returns false if the name is not found
+func mapField(value reflect.Value, field reflect.StructField, source formSource, name string) (bool, error) {
+	if source.header {
+		name = textproto.CanonicalMIMEHeaderKey(name)
+	}
+	switch value.Type() {
+	case fileHeaderType:
+		files := source.files[name]
+		if len(files) == 0 {
+			return false, nil
+		}
+		value.Set(reflect.ValueOf(files[0]))
+		return true, nil
+	case reflect.SliceOf(fileHeaderType):
+		files, ok := source.files[name]
+		if !ok {
+			return false, nil
+		}
+		value.Set(reflect.ValueOf(files))
+		return true, nil
+	}
+	if value.Kind() == reflect.Map {
+		return setMap(value, field, source.values, name)
+	}
+	values, ok := source.values[name]
+	if !ok {
+		return false, nil
+	}
+	return true, setField(value, field, values)
+}
+
+// setMap set the values of the keys like name[key] into the map, as Context.GetQueryMap.
+// the slice value of map takes all the values of the key, others take the first one
+func setMap(value reflect.Value, field reflect.StructField, form map[string][]string, name string) (bool, error) {
+	tp := value.Type()
+	set := false
+	for k, values := range form {
+		i := strings.IndexByte(k, '[')
+		if i < 1 || k[0:i] != name {
+			continue
+		}
+		j := strings.IndexByte(k[i+1:], ']')
+		if j < 1 {
+			continue
+		}
+		key := reflect.New(tp.Key()).Elem()
+		if err := setValue(key, k[i+1:][:j], field); err != nil {
+			return false, err
+		}
+		elem := reflect.New(tp.Elem()).Elem()
+		if err := setField(elem, field, values); err != nil {
+			return false, err
+		}
+		if value.IsNil() {
+			value.Set(reflect.MakeMap(tp))
+		}
+		value.SetMapIndex(key, elem)
+		set = true
+	}
+	return set, nil
+}
+
+// setField set the values to the field, slice and array take all the values, others take the first one
+func setField(value reflect.Value, field reflect.StructField, values []string) error {
+	switch value.Kind() {
+	case reflect.Slice:
+		slice := reflect.MakeSlice(value.Type(), len(values), len(values))
+		for i, v := range values {
+			if err := setValue(slice.Index(i), v, field); err != nil {
+				return err
+			}
+		}
+		value.Set(slice)
+		return nil
+	case reflect.Array:
+		if len(values) != value.Len() {
+			return fmt.Errorf("%q is not valid value for %s", values, value.Type())
+		}
+		for i, v := range values {
+			if err := setValue(value.Index(i), v, field); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	return setValue(value, values[0], field)
+}
+
+// setTime parse the time by the tags of field:
+// time_format is the layout of time.Parse, or unix, unixmilli, unixnano for the timestamp, default is time.RFC3339.
+// time_utc:"1" parse the time in UTC and time_location parse it in the location, otherwise in the local time
+func setTime(value reflect.Value, val string, field reflect.StructField) error {
+	if val == "" {
+		value.Set(reflect.ValueOf(time.Time{}))
+		return nil
+	}
+	format := field.Tag.Get("time_format")
+	if format == "" {
+		format = time.RFC3339
+	}
+	switch format {
+	case "unix", "unixmilli", "unixnano":
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return err
+		}
+		var t time.Time
+		switch format {
+		case "unix":
+			t = time.Unix(n, 0)
+		case "unixmilli":
+			t = time.UnixMilli(n)
+		default:
+			t = time.Unix(0, n)
+		}
+		value.Set(reflect.ValueOf(t))
+		return nil
+	}
+	loc := time.Local
+	if utc, _ := strconv.ParseBool(field.Tag.Get("time_utc")); utc {
+		loc = time.UTC
+	}
+	if name := field.Tag.Get("time_location"); name != "" {
+		l, err := time.LoadLocation(name)
+		if err != nil {
+			return err
+		}
+		loc = l
+	}
+	t, err := time.ParseInLocation(format, val, loc)
+	if err != nil {
+		return err
+	}
+	value.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// setValue parse the string value to the kind of the field
+func setValue(value reflect.Value, val string, field reflect.StructField) error {
+	switch value.Type() {
+	case timeType:
+		return setTime(value, val, field)
+	case durationType:
+		if val == "" {
+			val = "0"
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(val))
+		if err != nil {
+			return err
+		}
+		value.SetInt(int64(d))
+		return nil
+	}
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return setValue(value.Elem(), val, field)
+	case reflect.String:
+		value.SetString(val)
+	case reflect.Bool:
+		if val == "" {
+			val = "false"
+		}
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if val == "" {
+			val = "0"
+		}
+		i, err := strconv.ParseInt(strings.TrimSpace(val), 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if val == "" {
+			val = "0"
+		}
+		u, err := strconv.ParseUint(strings.TrimSpace(val), 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		if val == "" {
+			val = "0"
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	default:
+		return errUnknownType
+	}
+	return nil
+}
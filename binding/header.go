@@ -0,0 +1,22 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import "net/http"
+
+type headerBinding struct {
+}
+
+func (headerBinding) Name() string {
+	return "header"
+}
+
+// Bind map the headers to the fields by the "header" tag, the names are case-insensitive
+func (headerBinding) Bind(r *http.Request, obj any) error {
+	if err := mapFormSource(obj, formSource{values: r.Header, header: true}, "header"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
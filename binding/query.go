@@ -0,0 +1,22 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package binding
+
+import "net/http"
+
+type queryBinding struct {
+}
+
+func (queryBinding) Name() string {
+	return "query"
+}
+
+// Bind map the query of url to the fields by the "form" tag
+func (queryBinding) Bind(r *http.Request, obj any) error {
+	if err := mapForm(obj, r.URL.Query(), "form"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
@@ -0,0 +1,19 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package binding
+
+type uriBinding struct {
+}
+
+func (uriBinding) Name() string {
+	return "uri"
+}
+
+func (uriBinding) BindUri(m map[string][]string, obj any) error {
+	if err := mapForm(obj, m, "uri"); err != nil {
+		return err
+	}
+	return validate(obj)
+}
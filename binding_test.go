@@ -0,0 +1,191 @@
+package vex
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/axzed/vex/binding"
+)
+
+type bindPage struct {
+	Page int `form:"page" validate:"min=1"`
+	Size int `form:"size"`
+}
+
+type bindAuthor struct {
+	Name string `form:"name"`
+	Age  int    `form:"age"`
+}
+
+type bindFilter struct {
+	bindPage
+	Tags     []string          `form:"tags"`
+	Sort     map[string]string `form:"sort"`
+	Scores   map[string]int    `form:"scores"`
+	Since    time.Time         `form:"since" time_format:"2006-01-02" time_location:"Asia/Shanghai"`
+	Until    time.Time         `form:"until" time_format:"unix"`
+	Timeout  time.Duration     `form:"timeout"`
+	Author   bindAuthor        `form:"author"`
+	Reviewer *bindAuthor       `form:"reviewer"`
+	Ignored  string            `form:"-"`
+}
+
+func TestBindQuery(t *testing.T) {
+	url := "/?page=2&size=10&tags=go&tags=web&sort[name]=asc&sort[id]=desc&scores[alice]=90" +
+		"&since=2022-10-01&until=1664582400&timeout=3s&author.name=vex&author.age=3&Ignored=x"
+	ctx := &Context{R: httptest.NewRequest(http.MethodGet, url, nil), W: newResponseWriter(httptest.NewRecorder())}
+	var f bindFilter
+	if err := ctx.BindQuery(&f); err != nil {
+		t.Fatal(err)
+	}
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	if f.Page != 2 || f.Size != 10 {
+		t.Errorf("nested struct = %+v", f.bindPage)
+	}
+	if strings.Join(f.Tags, ",") != "go,web" {
+		t.Errorf("Tags = %v", f.Tags)
+	}
+	if len(f.Sort) != 2 || f.Sort["name"] != "asc" || f.Sort["id"] != "desc" || f.Scores["alice"] != 90 {
+		t.Errorf("maps = %v %v", f.Sort, f.Scores)
+	}
+	if !f.Since.Equal(time.Date(2022, 10, 1, 0, 0, 0, 0, shanghai)) || f.Until.Unix() != 1664582400 {
+		t.Errorf("time = %v %v", f.Since, f.Until)
+	}
+	if f.Timeout != 3*time.Second {
+		t.Errorf("Timeout = %v", f.Timeout)
+	}
+	if f.Author != (bindAuthor{Name: "vex", Age: 3}) || f.Reviewer != nil {
+		t.Errorf("tagged struct = %+v %+v", f.Author, f.Reviewer)
+	}
+	if f.Ignored != "" {
+		t.Errorf("Ignored = %q", f.Ignored)
+	}
+
+	// the same Validator runs after binding
+	ctx = &Context{R: httptest.NewRequest(http.MethodGet, "/?page=0", nil), W: newResponseWriter(httptest.NewRecorder())}
+	if err := ctx.BindQuery(&bindFilter{}); err == nil || ctx.W.Status() != http.StatusBadRequest {
+		t.Errorf("validation got %v with status %d, want the error and 400", err, ctx.W.Status())
+	}
+	ctx = &Context{R: httptest.NewRequest(http.MethodGet, "/?page=1&since=2022/10/01", nil)}
+	if err := ctx.ShouldBind(&bindFilter{}, binding.Query); err == nil || !strings.Contains(err.Error(), "since") {
+		t.Errorf("invalid time got %v", err)
+	}
+}
+
+func TestBindForm(t *testing.T) {
+	type login struct {
+		User     string `form:"user" validate:"required"`
+		Remember bool   `form:"remember"`
+		Lang     string `form:"lang"`
+	}
+	newRequest := func() *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/?lang=go", strings.NewReader("user=vex&remember=true"))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	ctx := &Context{R: newRequest()}
+	var l login
+	if err := ctx.ShouldBind(&l, binding.Form); err != nil || l != (login{User: "vex", Remember: true, Lang: "go"}) {
+		t.Errorf("Form got %+v, %v", l, err)
+	}
+	l = login{}
+	ctx = &Context{R: newRequest()}
+	if err := ctx.ShouldBind(&l, binding.FormPost); err != nil || l != (login{User: "vex", Remember: true}) {
+		t.Errorf("FormPost got %+v, %v", l, err)
+	}
+}
+
+func TestBindFormMultipart(t *testing.T) {
+	type upload struct {
+		Title       string                  `form:"title"`
+		Avatar      *multipart.FileHeader   `form:"avatar" validate:"required"`
+		Attachments []*multipart.FileHeader `form:"attachments"`
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("title", "photos")
+	for _, file := range []struct{ field, name string }{{"avatar", "me.png"}, {"attachments", "a.txt"}, {"attachments", "b.txt"}} {
+		fw, _ := mw.CreateFormFile(file.field, file.name)
+		fw.Write([]byte(file.name))
+	}
+	mw.Close()
+	r := httptest.NewRequest(http.MethodPost, "/", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	var u upload
+	if err := (&Context{R: r}).ShouldBind(&u, binding.FormMultipart); err != nil {
+		t.Fatal(err)
+	}
+	if u.Title != "photos" || u.Avatar == nil || u.Avatar.Filename != "me.png" || len(u.Attachments) != 2 || u.Attachments[1].Filename != "b.txt" {
+		t.Errorf("multipart got %+v", u)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("title=x"))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if err := (&Context{R: r}).ShouldBind(&upload{}, binding.FormMultipart); err == nil {
+		t.Errorf("FormMultipart should fail for the body not multipart")
+	}
+}
+
+func TestBindHeader(t *testing.T) {
+	type headers struct {
+		RequestID string   `header:"x-request-id" validate:"required"`
+		Accept    []string `header:"Accept"`
+		Limit     int      `header:"X-Rate-Limit"`
+	}
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Request-Id", "abc")
+	r.Header.Add("Accept", "text/html")
+	r.Header.Add("Accept", "application/json")
+	r.Header.Set("X-Rate-Limit", "100")
+	ctx := &Context{R: r, W: newResponseWriter(httptest.NewRecorder())}
+	var h headers
+	if err := ctx.BindHeader(&h); err != nil {
+		t.Fatal(err)
+	}
+	if h.RequestID != "abc" || len(h.Accept) != 2 || h.Limit != 100 {
+		t.Errorf("header got %+v", h)
+	}
+	ctx = &Context{R: httptest.NewRequest(http.MethodGet, "/", nil), W: newResponseWriter(httptest.NewRecorder())}
+	if err := ctx.BindHeader(&headers{}); err == nil {
+		t.Errorf("missing required header should fail")
+	}
+}
+
+type bindCategory struct {
+	Name   string        `form:"name"`
+	Parent *bindCategory `form:"parent"`
+}
+
+type bindNode struct {
+	ID   string `form:"id"`
+	Next *bindNode
+}
+
+func TestBindRecursiveStruct(t *testing.T) {
+	var c bindCategory
+	ctx := &Context{R: httptest.NewRequest(http.MethodGet, "/?name=a", nil)}
+	if err := ctx.ShouldBind(&c, binding.Query); err != nil || c.Name != "a" || c.Parent != nil {
+		t.Errorf("got %+v, %v, want the category without parent", c, err)
+	}
+
+	c = bindCategory{}
+	ctx = &Context{R: httptest.NewRequest(http.MethodGet, "/?name=a&parent.name=b&parent.parent.name=c", nil)}
+	if err := ctx.ShouldBind(&c, binding.Query); err != nil {
+		t.Fatal(err)
+	}
+	if c.Parent == nil || c.Parent.Name != "b" || c.Parent.Parent == nil || c.Parent.Parent.Name != "c" || c.Parent.Parent.Parent != nil {
+		t.Errorf("got %+v, want the parents b and c", c)
+	}
+
+	var n bindNode
+	ctx = &Context{R: httptest.NewRequest(http.MethodGet, "/?id=1", nil)}
+	if err := ctx.ShouldBind(&n, binding.Query); err != nil || n.ID != "1" || n.Next != nil {
+		t.Errorf("got %+v, %v, want the node without next", n, err)
+	}
+}
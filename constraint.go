@@ -0,0 +1,113 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// ParamConstraint check the value of a url param like /orders/:id<int>
+// if it returns false the router will try the other routes
+type ParamConstraint func(value string) bool
+
+// builtinConstraints are the constraints can be used in every engine
+//
+//	/orders/:id<int>
+//	/u/:uid<uuid>
+var builtinConstraints = map[string]ParamConstraint{
+	"int":   isInt,
+	"uint":  isUint,
+	"float": isFloat,
+	"alpha": isAlpha,
+	"alnum": isAlnum,
+	"uuid":  isUUID,
+}
+
+// RegisterConstraint add a custom param constraint to the engine, it can replace the builtin one
+// the constraint must be registered before the routes use it,
+// otherwise the name is treated as a regular expression
+//
+//	engine.RegisterConstraint("even", func(v string) bool { ... })
+//	g.GET("/num/:n<even>", handle)
+func (e *Engine) RegisterConstraint(name string, constraint ParamConstraint) {
+	e.router.mu.Lock()
+	defer e.router.mu.Unlock()
+	if e.constraints == nil {
+		e.constraints = make(map[string]ParamConstraint)
+	}
+	e.constraints[name] = constraint
+}
+
+// compileConstraint find the constraint by name in the custom and builtin constraints,
+// if not found the name is compiled as a regular expression which must match the whole value
+func compileConstraint(name string, constraints map[string]ParamConstraint) (ParamConstraint, error) {
+	if constraint, ok := constraints[name]; ok {
+		return constraint, nil
+	}
+	if constraint, ok := builtinConstraints[name]; ok {
+		return constraint, nil
+	}
+	re, err := regexp.Compile("^(?:" + name + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid constraint '%s': %w", name, err)
+	}
+	return re.MatchString, nil
+}
+
+func isInt(value string) bool {
+	_, err := strconv.ParseInt(value, 10, 64)
+	return err == nil
+}
+
+func isUint(value string) bool {
+	_, err := strconv.ParseUint(value, 10, 64)
+	return err == nil
+}
+
+func isFloat(value string) bool {
+	_, err := strconv.ParseFloat(value, 64)
+	return err == nil
+}
+
+func isAlpha(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if c := value[i] | 0x20; c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return value != ""
+}
+
+func isAlnum(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; !('0' <= c && c <= '9') && !('a' <= c|0x20 && c|0x20 <= 'z') {
+			return false
+		}
+	}
+	return value != ""
+}
+
+// isUUID check the value in the form of 8-4-4-4-12 hex digits
+func isUUID(value string) bool {
+	if len(value) != 36 {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9') && !('a' <= c|0x20 && c|0x20 <= 'f') {
+				return false
+			}
+		}
+	}
+	return true
+}
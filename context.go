@@ -5,6 +5,8 @@
 package vex
 
 import (
+	"context"
+	"crypto/x509"
 	"errors"
 	"github.com/axzed/vex/binding"
 	vexLog "github.com/axzed/vex/log"
@@ -12,30 +14,171 @@ import (
 	"html/template"
 	"io"
 	"log"
+	"math"
 	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
 	"strings"
 	"sync"
+	"time"
 )
 
 var defaultMaxMemory = 32 << 20 // 32M
 
+// abortIndex is the index of handler chain after Abort, it is larger than any chain's length,
+// and small enough that Next increasing it never overflows
+const abortIndex = math.MaxInt8 / 2
+
 // Context is the most important part of vex framework. It allows us to pass variables between middleware,
 // manage the flow, validate the JSON of a request and render a JSON response for example
 type Context struct {
-	W                     http.ResponseWriter // response
-	R                     *http.Request       // request
-	engine                *Engine             // Context's engine
-	queryCache            url.Values          // handle the query of url
-	formCache             url.Values          // handle the query by HTML post
-	DisallowUnknownFields bool                // control the json fields in json
-	IsValid               bool                // control the json valid
-	StatusCode            int                 // get the request status code
-	Logger                *vexLog.Logger      // the logger in context (print the recover log)
-	Keys                  map[string]any      // the key-value store for this context's lifetime
-	mu                    sync.RWMutex        // protect Keys concurrent read and write
+	W                     ResponseWriter // response, it records the status and size written
+	R                     *http.Request  // request
+	engine                *Engine        // Context's engine
+	queryCache            url.Values     // handle the query of url
+	formCache             url.Values     // handle the query by HTML post
+	DisallowUnknownFields bool           // control the json fields in json
+	IsValid               bool           // control the json valid
+	StatusCode            int            // get the request status code
+	Logger                *vexLog.Logger // the logger in context (print the recover log)
+	Params                Params         // the url params matched by the router like :id
+	Keys                  map[string]any // the key-value store for this context's lifetime
+	mu                    sync.RWMutex   // protect Keys concurrent read and write
+	handlers              []HandleFunc   // the handler chain of the matched route
+	index                 int            // the index of the handler executing in the chain
+	writermem             responseWriter // the ResponseWriter of request, W points to it
+}
+
+// reset the state left by the last request before the context is used by a request,
+// the slice of Params is kept to reuse its memory
+func (c *Context) reset() {
+	c.queryCache = nil
+	c.formCache = nil
+	c.DisallowUnknownFields = false
+	c.IsValid = false
+	c.StatusCode = 0
+	c.Params = c.Params[:0]
+	c.mu.Lock()
+	c.Keys = nil
+	c.mu.Unlock()
+	c.handlers = nil
+	c.index = -1
+}
+
+// Copy returns a read-only copy of the context, it must be used when the context is used
+// by a goroutine after the handler returns, because the context is put back to the pool and reused by another request.
+// the copy keeps the request, Params and Keys, its response can not be written and its handler chain is aborted.
+// the request's context of the copy is canceled after the handler returns
+//
+//	ctx.Set("user", user)
+//	cp := ctx.Copy()
+//	pool.Submit(func() {
+//	    user, _ := cp.Get("user")
+//	    audit(cp.R.URL.Path, user)
+//	})
+func (c *Context) Copy() *Context {
+	cp := c.clone()
+	header := c.W.Header().Clone()
+	cp.W = &responseWriter{
+		ResponseWriter: &copiedResponseWriter{header: header},
+		status:         c.W.Status(),
+		size:           c.W.Size(),
+	}
+	cp.handlers = nil
+	cp.index = abortIndex
+	return cp
+}
+
+// ErrCopiedContext is returned by the writes of a context returned by Context.Copy
+var ErrCopiedContext = errors.New("vex: the response of copied context can not be written")
+
+// copiedResponseWriter is the response of a copied context, it rejects the writes
+type copiedResponseWriter struct {
+	header http.Header
+}
+
+func (w *copiedResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *copiedResponseWriter) Write([]byte) (int, error) {
+	return 0, ErrCopiedContext
+}
+
+func (w *copiedResponseWriter) WriteHeader(int) {}
+
+// clone return a context not in the pool with the same state, its Params and Keys are copied,
+// so it can be used by another goroutine after the request's context is put back to the pool
+func (c *Context) clone() *Context {
+	cp := &Context{
+		W:                     c.W,
+		R:                     c.R,
+		engine:                c.engine,
+		queryCache:            c.queryCache,
+		formCache:             c.formCache,
+		DisallowUnknownFields: c.DisallowUnknownFields,
+		IsValid:               c.IsValid,
+		StatusCode:            c.StatusCode,
+		Logger:                c.Logger,
+		Params:                append(Params(nil), c.Params...),
+		handlers:              c.handlers,
+		index:                 c.index,
+	}
+	c.mu.RLock()
+	if c.Keys != nil {
+		cp.Keys = make(map[string]any, len(c.Keys))
+		for k, v := range c.Keys {
+			cp.Keys[k] = v
+		}
+	}
+	c.mu.RUnlock()
+	return cp
+}
+
+// Next should be used only inside middleware.
+// It executes the pending handlers in the chain inside the calling handler.
+//
+//	func(ctx *vex.Context) {
+//	    start := time.Now()
+//	    ctx.Next()
+//	    log.Println(time.Since(start))
+//	}
+func (c *Context) Next() {
+	c.index++
+	for c.index < len(c.handlers) {
+		c.handlers[c.index](c)
+		c.index++
+	}
+}
+
+// IsAborted returns true if the current context was aborted.
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
+// Abort prevents pending handlers from being called. Note that this will not stop the current handler.
+// Let's say you have an authorization middleware that validates that the current request is authorized.
+// If the authorization fails (ex: the password does not match), call Abort to ensure the remaining handlers
+// for this request are not called.
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+// AbortWithStatus calls `Abort()` and writes the headers with the specified status code.
+// For example, a failed attempt to authenticate a request could use: context.AbortWithStatus(401).
+func (c *Context) AbortWithStatus(code int) {
+	c.Abort()
+	c.StatusCode = code
+	c.W.WriteHeader(code)
+}
+
+// AbortWithStatusJSON calls `Abort()` and then `JSON` internally.
+// This method stops the chain, writes the status code and return a JSON body.
+// It also sets the Content-Type as "application/json".
+func (c *Context) AbortWithStatusJSON(code int, obj any) error {
+	c.Abort()
+	return c.JSON(code, obj)
 }
 
 // Set is used to store a new key/value pair exclusively for this context.
@@ -56,6 +199,79 @@ func (c *Context) Get(key string) (value any, ok bool) {
 	return
 }
 
+// Context implements context.Context, so it can be passed to the functions accepting context.Context,
+// like the database queries and rpc calls, they are canceled when the client goes away or the request times out
+//
+//	rows, err := db.QueryContext(ctx, "SELECT ...")
+var _ context.Context = (*Context)(nil)
+
+// Deadline returns the deadline of the request's context
+func (c *Context) Deadline() (deadline time.Time, ok bool) {
+	if c.R == nil {
+		return
+	}
+	return c.R.Context().Deadline()
+}
+
+// Done returns the channel closed when the request's context is canceled
+func (c *Context) Done() <-chan struct{} {
+	if c.R == nil {
+		return nil
+	}
+	return c.R.Context().Done()
+}
+
+// Err returns the error of the request's context after Done is closed
+func (c *Context) Err() error {
+	if c.R == nil {
+		return nil
+	}
+	return c.R.Context().Err()
+}
+
+// Value returns the value of key in the request's context,
+// if it is not found and the key is a string, the value set by Context.Set is returned
+func (c *Context) Value(key any) any {
+	if c.R != nil {
+		if value := c.R.Context().Value(key); value != nil {
+			return value
+		}
+	}
+	if name, ok := key.(string); ok {
+		if value, exists := c.Get(name); exists {
+			return value
+		}
+	}
+	return nil
+}
+
+// ClientCertificate returns the client certificate verified by the ClientCAFile of TLSOptions,
+// it is nil if the request is not over TLS or the client certificate is not verified
+//
+//	cert := ctx.ClientCertificate()
+//	if cert != nil {
+//	    user := cert.Subject.CommonName
+//	}
+func (c *Context) ClientCertificate() *x509.Certificate {
+	if c.R.TLS == nil || len(c.R.TLS.VerifiedChains) == 0 || len(c.R.TLS.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return c.R.TLS.VerifiedChains[0][0]
+}
+
+// Param returns the value of the URL param.
+// It is a shortcut for c.Params.ByName(key)
+//
+//	router.GET("/user/:id", func(c *vex.Context) {
+//	    // a GET request to /user/john
+//	    id := c.Param("id") // id == "john"
+//	})
+//
+// the value matched by "*" is stored under "*" and the remainder matched by "**" under "**"
+func (c *Context) Param(key string) string {
+	return c.Params.ByName(key)
+}
+
 // initQueryCache get the query param in request url
 func (c *Context) initQueryCache() {
 	if c.R != nil {
@@ -235,7 +451,7 @@ func (c *Context) HTML(status int, html string) error {
 func (c *Context) HTMLTemplate(name string, data any, filename ...string) error {
 	// Default status 200
 	c.W.Header().Set("Content-Type", "text/html; charset=utf-8")
-	t := template.New(name)
+	t := template.New(name).Funcs(c.engine.funcMap)
 	t, err := t.ParseFiles(filename...)
 	if err != nil {
 		return err
@@ -248,7 +464,7 @@ func (c *Context) HTMLTemplate(name string, data any, filename ...string) error
 func (c *Context) HTMLTemplateGlob(name string, data any, pattern string) error {
 	// Default status 200
 	c.W.Header().Set("Content-Type", "text/html; charset=utf-8")
-	t := template.New(name)
+	t := template.New(name).Funcs(c.engine.funcMap)
 	t, err := t.ParseGlob(pattern)
 	if err != nil {
 		return err
@@ -358,6 +574,54 @@ func (c *Context) BindXML(obj any) error {
 	return c.MustBindWith(obj, binding.XML)
 }
 
+// BindQuery is a shortcut for c.MustBindWith(obj, binding.Query).
+// the query of url is mapped to the fields by the "form" tag
+//
+//	type Filter struct {
+//	    Page  int       `form:"page" validate:"min=1"`
+//	    Tags  []string  `form:"tags"`
+//	    Since time.Time `form:"since" time_format:"2006-01-02"`
+//	}
+func (c *Context) BindQuery(obj any) error {
+	return c.MustBindWith(obj, binding.Query)
+}
+
+// BindForm is a shortcut for c.MustBindWith(obj, binding.Form).
+// the query of url and the form of body, including the files of multipart form, are mapped by the "form" tag
+func (c *Context) BindForm(obj any) error {
+	return c.MustBindWith(obj, binding.Form)
+}
+
+// BindHeader is a shortcut for c.MustBindWith(obj, binding.Header).
+// the headers are mapped to the fields by the "header" tag
+func (c *Context) BindHeader(obj any) error {
+	return c.MustBindWith(obj, binding.Header)
+}
+
+// BindUri binds the passed struct pointer using binding.Uri.
+// It will abort the request with HTTP 400 if any error occurs.
+func (c *Context) BindUri(obj any) error {
+	if err := c.ShouldBindUri(obj); err != nil {
+		c.W.WriteHeader(http.StatusBadRequest)
+		return err
+	}
+	return nil
+}
+
+// ShouldBindUri binds the passed struct pointer using the specified binding engine.
+// the url params are mapped to the fields by the "uri" tag
+//
+//	type User struct {
+//	    ID int `uri:"id" validate:"required"`
+//	}
+func (c *Context) ShouldBindUri(obj any) error {
+	m := make(map[string][]string, len(c.Params))
+	for _, v := range c.Params {
+		m[v.Key] = append(m[v.Key], v.Value)
+	}
+	return binding.Uri.BindUri(m, obj)
+}
+
 // MustBindWith binds the passed struct pointer using the specified binding engine.
 // It will abort the request with HTTP 400 if any error occurs.
 // See the binding package.
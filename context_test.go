@@ -0,0 +1,128 @@
+package vex
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestContextBindUri(t *testing.T) {
+	type user struct {
+		ID   int    `uri:"id" validate:"required"`
+		Name string `uri:"name"`
+	}
+	ctx := &Context{Params: Params{{Key: "id", Value: "11"}, {Key: "name", Value: "vex"}}}
+	if ctx.Param("id") != "11" {
+		t.Fatalf("Param(id) = %q, want 11", ctx.Param("id"))
+	}
+	var u user
+	if err := ctx.ShouldBindUri(&u); err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != 11 || u.Name != "vex" {
+		t.Errorf("bind uri = %+v", u)
+	}
+
+	ctx.Params = Params{{Key: "id", Value: "abc"}}
+	if err := ctx.ShouldBindUri(&user{}); err == nil {
+		t.Error("expected error for invalid int param")
+	}
+}
+
+type contextKey string
+
+func TestContextAsContext(t *testing.T) {
+	var ctx context.Context = &Context{}
+	if _, ok := ctx.Deadline(); ok || ctx.Done() != nil || ctx.Err() != nil || ctx.Value("a") != nil {
+		t.Errorf("the context without request should never be done")
+	}
+
+	parent, cancel := context.WithTimeout(context.WithValue(context.Background(), contextKey("user"), "vex"), time.Minute)
+	c := &Context{R: httptest.NewRequest("GET", "/", nil).WithContext(parent)}
+	c.Set("user", "keys")
+	c.Set("role", "admin")
+	if deadline, ok := c.Deadline(); !ok || deadline.IsZero() {
+		t.Errorf("Deadline = %v, %v, want the deadline of request", deadline, ok)
+	}
+	if got := c.Value(contextKey("user")); got != "vex" {
+		t.Errorf("Value of request's context = %v, want vex", got)
+	}
+	if got := c.Value("role"); got != "admin" {
+		t.Errorf("Value of Keys = %v, want admin", got)
+	}
+	if got := c.Value("missing"); got != nil {
+		t.Errorf("Value of missing key = %v, want nil", got)
+	}
+
+	cancel()
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done is not closed after the request's context is canceled")
+	}
+	if c.Err() != context.Canceled {
+		t.Errorf("Err = %v, want %v", c.Err(), context.Canceled)
+	}
+}
+
+func TestContextReset(t *testing.T) {
+	engine := New()
+	c := engine.allocateContext().(*Context)
+	c.R = httptest.NewRequest("GET", "/?name=vex", nil)
+	c.GetQuery("name")
+	c.GetPost("name")
+	c.Set("user", "alice")
+	c.DisallowUnknownFields = true
+	c.IsValid = true
+	c.StatusCode = http.StatusCreated
+	c.Params = append(c.Params, Param{Key: "id", Value: "1"})
+
+	c.reset()
+	c.R = httptest.NewRequest("GET", "/?other=1", nil)
+	if _, ok := c.Get("user"); ok {
+		t.Errorf("Keys of the last request are kept after reset")
+	}
+	if name := c.GetQuery("name"); name != "" {
+		t.Errorf("query of the last request %q is kept after reset", name)
+	}
+	if c.DisallowUnknownFields || c.IsValid || c.StatusCode != 0 || len(c.Params) != 0 || c.index != -1 {
+		t.Errorf("state of the last request is kept after reset: %+v", c)
+	}
+}
+
+func TestContextCopy(t *testing.T) {
+	engine := New()
+	c := engine.allocateContext().(*Context)
+	c.W = newResponseWriter(httptest.NewRecorder())
+	c.R = httptest.NewRequest("GET", "/users/1", nil)
+	c.Params = append(c.Params, Param{Key: "id", Value: "1"})
+	c.Set("user", "alice")
+	called := false
+	c.handlers = []HandleFunc{func(ctx *Context) {}, func(ctx *Context) { called = true }}
+	c.index = 0
+
+	cp := c.Copy()
+	// the context is reused by the next request
+	c.reset()
+	c.Params = append(c.Params, Param{Key: "id", Value: "2"})
+	c.Set("user", "bob")
+
+	if user, _ := cp.Get("user"); user != "alice" {
+		t.Errorf("copy Keys user = %v, want alice", user)
+	}
+	if id := cp.Param("id"); id != "1" {
+		t.Errorf("copy Param id = %s, want 1", id)
+	}
+	if cp.R.URL.Path != "/users/1" {
+		t.Errorf("copy request path = %s, want /users/1", cp.R.URL.Path)
+	}
+	cp.Next()
+	if called || !cp.IsAborted() {
+		t.Errorf("handler chain of copy should be aborted")
+	}
+	if _, err := cp.W.Write([]byte("x")); err != ErrCopiedContext {
+		t.Errorf("write of copy got %v, want %v", err, ErrCopiedContext)
+	}
+}
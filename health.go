@@ -0,0 +1,130 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HealthChecker checks a dependency of the service, it returns nil if the dependency is healthy
+// ctx is done when the timeout of health check is reached
+type HealthChecker func(ctx context.Context) error
+
+// healthCheck is a registered HealthChecker
+type healthCheck struct {
+	name    string
+	checker HealthChecker
+}
+
+// HealthResult is the response of HealthzHandler and ReadyzHandler
+type HealthResult struct {
+	Status string            `json:"status"`           // "ok" or "fail"
+	Checks map[string]string `json:"checks,omitempty"` // the result of each checker, "ok" or the error
+}
+
+// RegisterHealthChecker add the checker by name, it is run by HealthzHandler and ReadyzHandler
+// with the HealthCheckTimeout of engine. the checker of the same name is replaced
+//
+//	engine.RegisterHealthChecker("db", db.Ping)
+func (e *Engine) RegisterHealthChecker(name string, checker HealthChecker) {
+	e.serverMu.Lock()
+	defer e.serverMu.Unlock()
+	for i, check := range e.healthChecks {
+		if check.name == name {
+			e.healthChecks[i].checker = checker
+			return
+		}
+	}
+	e.healthChecks = append(e.healthChecks, healthCheck{name: name, checker: checker})
+}
+
+// Ready reports whether the engine is serving and not shutting down
+func (e *Engine) Ready() bool {
+	return e.ready.Load()
+}
+
+// HealthzHandler answers the liveness probe, it runs all the health checkers and answers 200 if all of them are healthy,
+// otherwise 503. the result of each checker is rendered in JSON
+//
+//	engine.Group("/").GET("/healthz", engine.HealthzHandler)
+//	engine.Group("/").GET("/readyz", engine.ReadyzHandler)
+func (e *Engine) HealthzHandler(ctx *Context) {
+	e.renderHealth(ctx, true)
+}
+
+// ReadyzHandler answers the readiness probe as HealthzHandler, and it fails if the engine is not Ready,
+// it fails as soon as the shutdown begins so the load balancer stops sending requests
+func (e *Engine) ReadyzHandler(ctx *Context) {
+	e.renderHealth(ctx, e.Ready())
+}
+
+// renderHealth run the health checkers and render the result
+func (e *Engine) renderHealth(ctx *Context, ready bool) {
+	result := HealthResult{Status: "ok", Checks: e.checkHealth(ctx.R.Context())}
+	code := http.StatusOK
+	for _, status := range result.Checks {
+		if status != "ok" {
+			result.Status = "fail"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	if !ready {
+		result.Status = "fail"
+		code = http.StatusServiceUnavailable
+	}
+	ctx.JSON(code, result)
+}
+
+// checkHealth run the health checkers concurrently, each of them has the HealthCheckTimeout
+func (e *Engine) checkHealth(ctx context.Context) map[string]string {
+	e.serverMu.Lock()
+	checks := e.healthChecks
+	e.serverMu.Unlock()
+	results := make(map[string]string, len(checks))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range checks {
+		wg.Add(1)
+		go func(check healthCheck) {
+			defer wg.Done()
+			err := runHealthChecker(ctx, check.checker, e.HealthCheckTimeout)
+			status := "ok"
+			if err != nil {
+				status = err.Error()
+			}
+			mu.Lock()
+			results[check.name] = status
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+	return results
+}
+
+// runHealthChecker run the checker with the timeout, the checker ignoring ctx is not waited after the timeout
+func runHealthChecker(ctx context.Context, checker HealthChecker, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- checker(ctx)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return errors.New("timeout")
+		}
+		return ctx.Err()
+	}
+}
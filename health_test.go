@@ -0,0 +1,70 @@
+package vex
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHealthHandlers(t *testing.T) {
+	engine := New()
+	engine.HealthCheckTimeout = 50 * time.Millisecond
+	engine.Group("/").GET("/healthz", engine.HealthzHandler)
+	engine.Group("/").GET("/readyz", engine.ReadyzHandler)
+	engine.RegisterHealthChecker("db", func(ctx context.Context) error {
+		return nil
+	})
+
+	check := func(path string, code int, status string, checks map[string]string) {
+		t.Helper()
+		w := performRequest(engine, http.MethodGet, path)
+		var result HealthResult
+		if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		if w.Code != code || result.Status != status {
+			t.Errorf("GET %s = %d %s, want %d %s", path, w.Code, result.Status, code, status)
+		}
+		for name, want := range checks {
+			if result.Checks[name] != want {
+				t.Errorf("GET %s: check %s = %q, want %q", path, name, result.Checks[name], want)
+			}
+		}
+	}
+
+	check("/healthz", http.StatusOK, "ok", map[string]string{"db": "ok"})
+	// the engine is not serving yet
+	check("/readyz", http.StatusServiceUnavailable, "fail", map[string]string{"db": "ok"})
+	engine.ready.Store(true)
+	check("/readyz", http.StatusOK, "ok", map[string]string{"db": "ok"})
+
+	engine.RegisterHealthChecker("cache", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+	engine.RegisterHealthChecker("mq", func(ctx context.Context) error {
+		// ignore ctx, it is not waited after the timeout
+		time.Sleep(time.Second)
+		return nil
+	})
+	start := time.Now()
+	check("/healthz", http.StatusServiceUnavailable, "fail", map[string]string{
+		"db": "ok", "cache": "connection refused", "mq": "timeout",
+	})
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("health check took %v, the timeout is not applied", elapsed)
+	}
+
+	engine.RegisterHealthChecker("cache", func(ctx context.Context) error {
+		return nil
+	})
+	engine.RegisterHealthChecker("mq", func(ctx context.Context) error {
+		return nil
+	})
+	check("/readyz", http.StatusOK, "ok", map[string]string{"cache": "ok", "mq": "ok"})
+	engine.Shutdown(context.Background())
+	check("/readyz", http.StatusServiceUnavailable, "fail", nil)
+	check("/healthz", http.StatusOK, "ok", nil)
+}
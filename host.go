@@ -0,0 +1,142 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// HostRouter is the router bound to a host pattern, it is returned by Engine.Host
+type HostRouter struct {
+	*router
+}
+
+// Host return the router bound to the host pattern, its groups only match the request whose Host header matches the pattern
+// the pattern is split into labels by ".", a "{name}" label matches any one label and its value is captured in ctx.Params
+// like the url params, the other labels are matched ignoring the case. the port of Host header is not matched
+// the pattern with more literal labels is tried first, so "api.example.com" is matched before "{tenant}.example.com"
+// the request whose host is not matched by any pattern, or whose url has no route in the host's router,
+// falls back to the groups created by Engine.Group
+//
+//	api := engine.Host("{tenant}.example.com").Group("/api")
+//	api.GET("/users", func(ctx *vex.Context) {
+//	    tenant := ctx.Param("tenant")
+//	})
+func (e *Engine) Host(pattern string) *HostRouter {
+	labels, err := parseHost(pattern)
+	if err != nil {
+		panic(err)
+	}
+	e.router.mu.Lock()
+	defer e.router.mu.Unlock()
+	for _, r := range e.hosts {
+		if r.host == pattern {
+			return r
+		}
+	}
+	r := &HostRouter{&router{
+		engine:     e,
+		mu:         e.router.mu,
+		host:       pattern,
+		hostLabels: labels,
+	}}
+	e.hosts = append(e.hosts, r)
+	sort.SliceStable(e.hosts, func(i, j int) bool {
+		return literalLabels(e.hosts[i].hostLabels) > literalLabels(e.hosts[j].hostLabels)
+	})
+	return r
+}
+
+// literalLabels count the labels of host pattern which are not "{name}"
+func literalLabels(labels []string) int {
+	n := 0
+	for _, label := range labels {
+		if label[0] != '{' {
+			n++
+		}
+	}
+	return n
+}
+
+// parseHost split the host pattern into labels, the literal labels are in lower case
+func parseHost(pattern string) ([]string, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("vex: host pattern is empty")
+	}
+	labels := strings.Split(pattern, ".")
+	names := make(map[string]bool, len(labels))
+	for i, label := range labels {
+		if label == "" {
+			return nil, fmt.Errorf("vex: host pattern '%s' has an empty label", pattern)
+		}
+		if !strings.ContainsAny(label, "{}") {
+			labels[i] = strings.ToLower(label)
+			continue
+		}
+		if len(label) < 3 || label[0] != '{' || label[len(label)-1] != '}' || strings.ContainsAny(label[1:len(label)-1], "{}") {
+			return nil, fmt.Errorf("vex: host pattern '%s' has an invalid param label '%s'", pattern, label)
+		}
+		name := label[1 : len(label)-1]
+		if names[name] {
+			return nil, fmt.Errorf("vex: host pattern '%s' has the duplicate param '%s'", pattern, name)
+		}
+		names[name] = true
+	}
+	return labels, nil
+}
+
+// matchHost check the host of request matches the router's pattern, the values of "{name}" labels are appended to params
+// the router without pattern matches nothing, it is the fallback of the engine
+func (r *router) matchHost(host string, params *Params) bool {
+	if r.hostLabels == nil {
+		return false
+	}
+	host = stripHostPort(host)
+	base := 0
+	if params != nil {
+		base = len(*params)
+	}
+	last := len(r.hostLabels) - 1
+	for i, label := range r.hostLabels {
+		value := host
+		if i < last {
+			dot := strings.IndexByte(host, '.')
+			if dot < 0 {
+				break
+			}
+			value, host = host[:dot], host[dot+1:]
+		} else if strings.IndexByte(host, '.') >= 0 {
+			break
+		}
+		if value == "" {
+			break
+		}
+		if label[0] == '{' {
+			if params != nil {
+				*params = append(*params, Param{Key: label[1 : len(label)-1], Value: value})
+			}
+		} else if !strings.EqualFold(label, value) {
+			break
+		}
+		if i == last {
+			return true
+		}
+	}
+	if params != nil {
+		*params = (*params)[:base]
+	}
+	return false
+}
+
+// stripHostPort remove the port and the trailing dot of host
+func stripHostPort(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"context"
+)
+
+// HookFunc is the hook of engine's lifecycle, like opening or closing the resources used by the handlers
+type HookFunc func(ctx context.Context) error
+
+// OnStart add the hooks run in order before the first server of engine starts serving, they run once.
+// if a hook returns error, the rest hooks do not run and Run returns the error without serving
+//
+//	db := vorm.Open("mysql", dsn)
+//	pool, _ := vpool.NewPool(100)
+//	grpcServer, _ := rpc.NewGrpcServer(":9111")
+//	engine.OnStart(func(ctx context.Context) error {
+//	    go grpcServer.Run()
+//	    return nil
+//	})
+//	engine.OnShutdown(func(ctx context.Context) error {
+//	    grpcServer.GracefulStop()
+//	    return nil
+//	}, func(ctx context.Context) error {
+//	    pool.Release()
+//	    return nil
+//	}, func(ctx context.Context) error {
+//	    return db.Close()
+//	})
+func (e *Engine) OnStart(hooks ...HookFunc) {
+	e.serverMu.Lock()
+	defer e.serverMu.Unlock()
+	e.startHooks = append(e.startHooks, hooks...)
+}
+
+// OnReady add the hooks run in order after the OnStart hooks, when the listener of first server is opened.
+// the engine is ready after they run, the error of hook is logged
+func (e *Engine) OnReady(hooks ...HookFunc) {
+	e.serverMu.Lock()
+	defer e.serverMu.Unlock()
+	e.readyHooks = append(e.readyHooks, hooks...)
+}
+
+// OnShutdown add the hooks run by Shutdown after the in-flight requests are done,
+// they run in the reverse order of adding like defer, so the resource opened first is closed last.
+// ctx of Shutdown is passed to them, all of them run even if some return error
+func (e *Engine) OnShutdown(hooks ...HookFunc) {
+	e.serverMu.Lock()
+	defer e.serverMu.Unlock()
+	e.shutdownHooks = append(e.shutdownHooks, hooks...)
+}
+
+// start run the OnStart and OnReady hooks once, it returns the error of OnStart hooks to every server
+func (e *Engine) start() error {
+	e.startOnce.Do(func() {
+		e.serverMu.Lock()
+		startHooks, readyHooks := e.startHooks, e.readyHooks
+		e.serverMu.Unlock()
+		ctx := context.Background()
+		for _, hook := range startHooks {
+			if e.startErr = hook(ctx); e.startErr != nil {
+				return
+			}
+		}
+		for _, hook := range readyHooks {
+			if err := hook(ctx); err != nil {
+				e.Logger.Error(err)
+			}
+		}
+		e.serverMu.Lock()
+		e.ready.Store(!e.shuttingDown)
+		e.serverMu.Unlock()
+	})
+	return e.startErr
+}
+
+// runShutdownHooks run the OnShutdown hooks in reverse order and return the first error
+func (e *Engine) runShutdownHooks(ctx context.Context) error {
+	e.serverMu.Lock()
+	hooks := e.shutdownHooks
+	e.serverMu.Unlock()
+	var firstErr error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
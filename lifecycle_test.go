@@ -0,0 +1,97 @@
+package vex
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLifecycleHooks(t *testing.T) {
+	engine := New()
+	engine.ShutdownSignals = nil
+	var mu sync.Mutex
+	var events []string
+	record := func(event string) HookFunc {
+		return func(ctx context.Context) error {
+			mu.Lock()
+			events = append(events, event)
+			mu.Unlock()
+			return nil
+		}
+	}
+	engine.OnStart(record("open db"), record("start grpc"))
+	engine.OnReady(record("ready"))
+	engine.OnShutdown(record("close db"), record("stop grpc"))
+	started := make(chan struct{})
+	engine.Group("/").GET("/slow", func(ctx *Context) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		record("request done")(ctx.R.Context())
+		ctx.String(http.StatusOK, "done")
+	})
+	if engine.Ready() {
+		t.Errorf("engine should not be ready before serving")
+	}
+	url, done := startEngine(t, engine)
+	go http.Get(url + "/slow")
+	<-started
+	if !engine.Ready() {
+		t.Errorf("engine should be ready while serving")
+	}
+
+	shutdownErr := make(chan error, 1)
+	go func() {
+		shutdownErr <- engine.Shutdown(context.Background())
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if engine.Ready() {
+		t.Errorf("engine should not be ready as soon as the shutdown begins")
+	}
+	if err := <-shutdownErr; err != nil {
+		t.Errorf("Shutdown error: %v", err)
+	}
+	<-done
+
+	want := "open db,start grpc,ready,request done,stop grpc,close db"
+	if got := strings.Join(events, ","); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+}
+
+func TestLifecycleHookError(t *testing.T) {
+	engine := New()
+	engine.ShutdownSignals = nil
+	errOpen := errors.New("open db failed")
+	called := false
+	engine.OnStart(func(ctx context.Context) error {
+		return errOpen
+	}, func(ctx context.Context) error {
+		called = true
+		return nil
+	})
+	errClose := errors.New("close db failed")
+	engine.OnShutdown(func(ctx context.Context) error {
+		return errClose
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.RunListener(listener); err != errOpen {
+		t.Errorf("RunListener returned %v, want %v", err, errOpen)
+	}
+	if called || engine.Ready() {
+		t.Errorf("the hooks after the failed one should not run")
+	}
+	if _, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		t.Errorf("the listener should be closed")
+	}
+	if err := engine.Shutdown(context.Background()); err != errClose {
+		t.Errorf("Shutdown returned %v, want %v", err, errClose)
+	}
+}
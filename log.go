@@ -129,7 +129,7 @@ func LoggerWithConfig(conf LoggerConfig, next HandleFunc) HandleFunc {
 		// query method
 		method := r.Method
 		// query status code
-		statusCode := ctx.StatusCode
+		statusCode := ctx.W.Status()
 
 		// log display middleware
 		if raw != "" {
@@ -0,0 +1,59 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// EnvVexMode indicates environment name for vex mode.
+const EnvVexMode = "VEX_MODE"
+
+const (
+	// DebugMode indicates vex mode is debug, the route table is printed when the engine starts.
+	DebugMode = "debug"
+	// ReleaseMode indicates vex mode is release.
+	ReleaseMode = "release"
+	// TestMode indicates vex mode is test.
+	TestMode = "test"
+)
+
+var vexMode atomic.Value
+
+func init() {
+	SetMode(os.Getenv(EnvVexMode))
+}
+
+// SetMode sets vex mode according to input string, empty string means DebugMode
+func SetMode(value string) {
+	switch value {
+	case "":
+		value = DebugMode
+	case DebugMode, ReleaseMode, TestMode:
+	default:
+		panic("vex mode unknown: " + value + " (available mode: debug release test)")
+	}
+	vexMode.Store(value)
+}
+
+// Mode returns current vex mode.
+func Mode() string {
+	return vexMode.Load().(string)
+}
+
+// IsDebugging returns true if the framework is running in debug mode.
+// Use SetMode(vex.ReleaseMode) to disable debug mode.
+func IsDebugging() bool {
+	return Mode() == DebugMode
+}
+
+// debugPrint print the message to DefaultWriter in debug mode
+func debugPrint(format string, values ...any) {
+	if IsDebugging() {
+		fmt.Fprintf(DefaultWriter, "[VEX-debug] "+format, values...)
+	}
+}
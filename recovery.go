@@ -29,6 +29,8 @@ func Recovery(next HandleFunc) HandleFunc {
 		// exec the recover logic
 		defer func() {
 			if err := recover(); err != nil {
+				// the rest of chain must not run after the panic
+				ctx.Abort()
 				err2 := err.(error)
 				if err2 != nil {
 					var vError *verror.VError
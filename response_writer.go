@@ -0,0 +1,135 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+)
+
+const noWritten = -1
+
+// ResponseWriter is the http.ResponseWriter of Context, it records the status and size of the response,
+// so the middlewares like Logger get the status whenever the response is written by Render, File, Redirect or ctx.W.
+// it is installed for every request
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Hijacker
+	http.Flusher
+	http.Pusher
+
+	// Status returns the status code of the response, it is 200 if the header is not written yet
+	Status() int
+	// Size returns the number of bytes of the body written, it is -1 if the header is not written yet
+	Size() int
+	// Written returns true if the header is written
+	Written() bool
+	// WriteHeaderNow writes the header with the current status if it is not written yet
+	WriteHeaderNow()
+}
+
+// responseWriter is the ResponseWriter wrapping the http.ResponseWriter of the server
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+var _ ResponseWriter = (*responseWriter)(nil)
+
+// newResponseWriter return w itself if it is a ResponseWriter, otherwise the wrapper of w
+func newResponseWriter(w http.ResponseWriter) ResponseWriter {
+	if rw, ok := w.(ResponseWriter); ok {
+		return rw
+	}
+	rw := &responseWriter{}
+	rw.reset(w)
+	return rw
+}
+
+// reset the state before it is used by a request
+func (w *responseWriter) reset(writer http.ResponseWriter) {
+	w.ResponseWriter = writer
+	w.status = http.StatusOK
+	w.size = noWritten
+}
+
+// Unwrap returns the http.ResponseWriter wrapped, it is used by http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// WriteHeader writes the header with the code, the later codes are ignored after the header is written.
+// the informational codes 1xx except 101 are sent without writing the header
+func (w *responseWriter) WriteHeader(code int) {
+	if code >= 100 && code <= 199 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.Written() {
+		if code != w.status {
+			debugPrint("[WARNING] Headers were already written. Wanted to override status code %d with %d\n", w.status, code)
+		}
+		return
+	}
+	w.status = code
+	w.WriteHeaderNow()
+}
+
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+// Hijack lets the caller take over the connection, the response is regarded as written
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("vex: the response writer does not implement http.Hijacker")
+	}
+	if w.size < 0 {
+		w.size = 0
+	}
+	return hijacker.Hijack()
+}
+
+// Flush writes the header and sends the buffered data to the client
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Push initiates an HTTP/2 server push, it returns http.ErrNotSupported if the server does not support it
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
@@ -0,0 +1,110 @@
+package vex
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseWriterStatus(t *testing.T) {
+	engine := New()
+	var logs bytes.Buffer
+	engine.Use(func(next HandleFunc) HandleFunc {
+		return LoggerWithConfig(LoggerConfig{
+			Formatter: func(params *LogFormatterParams) string {
+				return fmt.Sprintf("%d ", params.StatusCode)
+			},
+			out: &logs,
+		}, next)
+	})
+	type result struct {
+		status  int
+		size    int
+		written bool
+	}
+	var got result
+	engine.UseHandleFunc(func(ctx *Context) {
+		ctx.Next()
+		got = result{ctx.W.Status(), ctx.W.Size(), ctx.W.Written()}
+	})
+	g := engine.Group("/")
+	g.GET("/file", func(ctx *Context) {
+		ctx.File("LICENSE")
+	})
+	g.GET("/redirect", func(ctx *Context) {
+		ctx.Redirect(http.StatusFound, "/file")
+	})
+	g.GET("/direct", func(ctx *Context) {
+		ctx.W.WriteHeader(http.StatusTeapot)
+		ctx.W.WriteHeader(http.StatusOK)
+		ctx.W.Write([]byte("tea"))
+	})
+	g.GET("/empty", func(ctx *Context) {})
+
+	w := performRequest(engine, http.MethodGet, "/file")
+	if got.status != http.StatusOK || got.size != w.Body.Len() || got.size == 0 || !got.written {
+		t.Errorf("File: got %+v, want 200 with %d bytes", got, w.Body.Len())
+	}
+	performRequest(engine, http.MethodGet, "/redirect")
+	if got.status != http.StatusFound || !got.written {
+		t.Errorf("Redirect: got %+v, want 302", got)
+	}
+	w = performRequest(engine, http.MethodGet, "/direct")
+	if got.status != http.StatusTeapot || got.size != 3 || w.Code != http.StatusTeapot {
+		t.Errorf("direct write: got %+v and response %d, want 418 with 3 bytes", got, w.Code)
+	}
+	performRequest(engine, http.MethodGet, "/empty")
+	if got.status != http.StatusOK || got.size != -1 || got.written {
+		t.Errorf("nothing written: got %+v, want 200 not written", got)
+	}
+	w = performRequest(engine, http.MethodHead, "/direct")
+	if got.status != http.StatusTeapot || got.size != 0 || w.Body.Len() != 0 {
+		t.Errorf("HEAD: got %+v with body %q, want 418 without body", got, w.Body.String())
+	}
+	if logs.String() != "200 302 418 200 418 " {
+		t.Errorf("Logger got the status %q", logs.String())
+	}
+}
+
+func TestResponseWriterInterfaces(t *testing.T) {
+	rw := newResponseWriter(httptest.NewRecorder())
+	if newResponseWriter(rw) != rw {
+		t.Errorf("the ResponseWriter should not be wrapped again")
+	}
+	if err := rw.Push("/app.js", nil); err != http.ErrNotSupported {
+		t.Errorf("Push got %v, want %v", err, http.ErrNotSupported)
+	}
+	if _, _, err := rw.Hijack(); err == nil {
+		t.Errorf("Hijack of the recorder should fail")
+	}
+	rw.Flush()
+	if !rw.Written() || rw.Status() != http.StatusOK {
+		t.Errorf("Flush should write the header")
+	}
+
+	engine := New()
+	engine.Group("/").GET("/hijack", func(ctx *Context) {
+		conn, buf, err := ctx.W.Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		buf.Flush()
+	})
+	srv := httptest.NewServer(engine)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/hijack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := bufio.NewReader(resp.Body).ReadString(0)
+	if body != "hijacked" {
+		t.Errorf("hijacked response got %q", body)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"net"
+	"sync"
+)
+
+// The signals of a running engine:
+//
+//	SIGINT, SIGTERM  shut down gracefully: stop accepting, wait for the in-flight requests at most ShutdownTimeout, then Run returns nil
+//	SIGHUP           restart with zero downtime (unix only) if it is set in Engine.RestartSignals: start the new process
+//	                 of the same executable, arguments and environment, which inherits the listening sockets,
+//	                 wait for it to serve, then shut down gracefully as SIGTERM. if the new process fails, the old one keeps serving
+//
+// they are configured by Engine.ShutdownSignals and Engine.RestartSignals, the restart is disabled by default:
+//
+//	engine.RestartSignals = []os.Signal{syscall.SIGHUP}
+//
+// the new process finds the inherited listeners by the environment variables below, the listener created by
+// Run, RunTLS, RunUnix, RunServer or Listen with the same network and address is inherited instead of a new one.
+// so the main function should run the engine in the same way after the restart, and exit after Run returns
+const (
+	// EnvVexListeners is the "network:address" list of the inherited listeners separated by ",",
+	// the listener of the i-th item is the file descriptor 3+i
+	EnvVexListeners = "VEX_LISTENERS"
+	// EnvVexReadyFD is the file descriptor written by the new process when it is serving
+	EnvVexReadyFD = "VEX_READY_FD"
+)
+
+// listenerKeys stores the "network:address" of the listeners created by Listen, it is passed to the new process of Restart
+var listenerKeys sync.Map
+
+// Listen announces on the local network address like net.Listen,
+// it returns the listener inherited from the old process of Restart if the network and address are the same
+//
+//	listener, err := vex.Listen("tcp", ":8080")
+//	err = engine.RunListener(listener)
+func Listen(network, address string) (net.Listener, error) {
+	key := network + ":" + address
+	listener, err := inheritListener(key)
+	if err != nil {
+		return nil, err
+	}
+	if listener == nil {
+		if listener, err = net.Listen(network, address); err != nil {
+			return nil, err
+		}
+	}
+	listenerKeys.Store(listener, key)
+	return listener, nil
+}
+
+// listenerKey return the "network:address" of listener, the address of Listen is used if the listener is created by it
+func listenerKey(listener net.Listener) string {
+	if key, ok := listenerKeys.Load(listener); ok {
+		return key.(string)
+	}
+	return listener.Addr().Network() + ":" + listener.Addr().String()
+}
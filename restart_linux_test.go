@@ -0,0 +1,157 @@
+//go:build linux
+
+package vex
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestRestartHelperProcess is the server restarted by TestRestartOnSIGHUP, it runs in the child process only
+func TestRestartHelperProcess(t *testing.T) {
+	addrFile := os.Getenv("VEX_TEST_RESTART_ADDR_FILE")
+	if addrFile == "" {
+		t.Skip("run by TestRestartOnSIGHUP")
+	}
+	engine := New()
+	if engine.RestartSignals != nil {
+		t.Fatalf("RestartSignals = %v, want nil by default", engine.RestartSignals)
+	}
+	engine.RestartSignals = []os.Signal{syscall.SIGHUP}
+	engine.ShutdownTimeout = 5 * time.Second
+	engine.Group("/").GET("/pid", func(ctx *Context) {
+		time.Sleep(20 * time.Millisecond)
+		ctx.String(http.StatusOK, "%d", os.Getpid())
+	})
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(addrFile, []byte(listener.Addr().String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.RunListener(listener); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestartOnSIGHUP(t *testing.T) {
+	if testing.Short() {
+		t.Skip("integration test")
+	}
+	addrFile := filepath.Join(t.TempDir(), "addr")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestRestartHelperProcess$")
+	cmd.Env = append(os.Environ(), "VEX_TEST_RESTART_ADDR_FILE="+addrFile, EnvVexMode+"="+ReleaseMode)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	oldPid := cmd.Process.Pid
+	defer cmd.Process.Kill()
+
+	var addr string
+	for i := 0; i < 500 && addr == ""; i++ {
+		b, _ := os.ReadFile(addrFile)
+		addr = string(b)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if addr == "" {
+		t.Fatal("the server is not started")
+	}
+
+	// every request uses a new connection, so it is accepted by the old or the new process
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}, Timeout: 5 * time.Second}
+	var (
+		total    int64
+		failures int64
+		mu       sync.Mutex
+		pids     = make(map[int]int)
+		stop     = make(chan struct{})
+		wg       sync.WaitGroup
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				atomic.AddInt64(&total, 1)
+				pid, err := getPid(client, "http://"+addr+"/pid")
+				if err != nil {
+					atomic.AddInt64(&failures, 1)
+					t.Errorf("request failed: %v", err)
+					continue
+				}
+				mu.Lock()
+				pids[pid]++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if err := cmd.Process.Signal(syscall.SIGHUP); err != nil {
+		t.Fatal(err)
+	}
+	// the old process exits after the in-flight requests are done
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		if err != nil {
+			t.Errorf("the old process exited with %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the old process does not exit")
+	}
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	newPid, err := getPid(client, "http://"+addr+"/pid")
+	if err != nil {
+		t.Fatalf("the new process is not serving: %v", err)
+	}
+	defer syscall.Kill(newPid, syscall.SIGTERM)
+	if newPid == oldPid {
+		t.Errorf("the request is served by the old process %d", oldPid)
+	}
+	if failures > 0 {
+		t.Errorf("%d of %d requests failed across the restart", failures, total)
+	}
+	if pids[oldPid] == 0 || pids[newPid] == 0 {
+		t.Errorf("requests served by the processes: %v, want both %d and %d", pids, oldPid, newPid)
+	}
+}
+
+func getPid(client *http.Client, url string) (int, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("status %d: %s", resp.StatusCode, body)
+	}
+	return strconv.Atoi(string(body))
+}
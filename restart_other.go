@@ -0,0 +1,25 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+//go:build !unix
+
+package vex
+
+import (
+	"errors"
+	"net"
+)
+
+// Restart is not supported on this platform
+func (e *Engine) Restart() error {
+	return errors.New("vex: restart is not supported on this platform")
+}
+
+// inheritListener returns nothing, the listeners can not be inherited on this platform
+func inheritListener(key string) (net.Listener, error) {
+	return nil, nil
+}
+
+// notifyReady does nothing on this platform
+func notifyReady() {}
@@ -0,0 +1,188 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+//go:build unix
+
+package vex
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// inherited is the state passed by the old process of Restart, it is loaded from the environment once
+var inherited struct {
+	once      sync.Once
+	mu        sync.Mutex
+	listeners map[string]net.Listener // the inherited listeners not used yet
+	ready     *os.File                // the pipe to notify the old process
+	err       error
+}
+
+// loadInherited read the inherited listeners and the ready pipe, the environment variables are removed
+// so they are not passed to the process started by the program
+func loadInherited() {
+	inherited.listeners = make(map[string]net.Listener)
+	keys := os.Getenv(EnvVexListeners)
+	readyFD := os.Getenv(EnvVexReadyFD)
+	os.Unsetenv(EnvVexListeners)
+	os.Unsetenv(EnvVexReadyFD)
+	if keys != "" {
+		for i, key := range strings.Split(keys, ",") {
+			file := os.NewFile(uintptr(3+i), key)
+			listener, err := net.FileListener(file)
+			file.Close()
+			if err != nil {
+				inherited.err = fmt.Errorf("vex: inherit the listener %s: %w", key, err)
+				return
+			}
+			inherited.listeners[key] = listener
+		}
+	}
+	if fd, err := strconv.Atoi(readyFD); err == nil {
+		inherited.ready = os.NewFile(uintptr(fd), "ready")
+	}
+}
+
+// inheritListener take the inherited listener of the key, it returns nil if there is none
+func inheritListener(key string) (net.Listener, error) {
+	inherited.once.Do(loadInherited)
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	if inherited.err != nil {
+		return nil, inherited.err
+	}
+	listener := inherited.listeners[key]
+	delete(inherited.listeners, key)
+	return listener, nil
+}
+
+// notifyReady tell the old process of Restart that the new process is serving, it is done once
+func notifyReady() {
+	inherited.once.Do(loadInherited)
+	inherited.mu.Lock()
+	defer inherited.mu.Unlock()
+	if inherited.ready != nil {
+		inherited.ready.Write([]byte{1})
+		inherited.ready.Close()
+		inherited.ready = nil
+	}
+}
+
+// Restart start the new process of the same executable, arguments and environment,
+// the listeners of running servers are inherited by it, see EnvVexListeners.
+// it returns nil after the new process is serving, then the engine should be shut down to hand over the requests,
+// as the engine does on RestartSignals. the new process is killed if it is not serving in ShutdownTimeout
+func (e *Engine) Restart() error {
+	e.serverMu.Lock()
+	if e.shuttingDown {
+		e.serverMu.Unlock()
+		return errors.New("vex: restart after the engine is shut down")
+	}
+	keys := make([]string, 0, len(e.servers))
+	files := make([]*os.File, 0, len(e.servers)+1)
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, running := range e.servers {
+		listener := running.listener
+		filer, ok := listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			e.serverMu.Unlock()
+			return fmt.Errorf("vex: the listener %s can not be inherited", listener.Addr())
+		}
+		file, err := filer.File()
+		if err != nil {
+			e.serverMu.Unlock()
+			return err
+		}
+		keys = append(keys, listenerKey(listener))
+		files = append(files, file)
+	}
+	e.serverMu.Unlock()
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	readyReader, readyWriter, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyReader.Close()
+	files = append(files, readyWriter)
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	cmd.Env = append(restartEnviron(),
+		EnvVexListeners+"="+strings.Join(keys, ","),
+		EnvVexReadyFD+"="+strconv.Itoa(3+len(keys)),
+	)
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	// close the write side in this process, so the read returns if the new process exits
+	readyWriter.Close()
+	files = files[:len(files)-1]
+
+	ready := make(chan error, 1)
+	go func() {
+		_, err := readyReader.Read(make([]byte, 1))
+		ready <- err
+	}()
+	var timeout <-chan time.Time
+	if e.ShutdownTimeout > 0 {
+		timer := time.NewTimer(e.ShutdownTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case err = <-ready:
+		if err != nil {
+			cmd.Wait()
+			return fmt.Errorf("vex: the new process exited before serving: %s", cmd.ProcessState)
+		}
+	case <-timeout:
+		cmd.Process.Kill()
+		cmd.Wait()
+		return errors.New("vex: the new process is not serving in time")
+	}
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+
+	e.serverMu.Lock()
+	e.handedOver = true
+	for _, running := range e.servers {
+		// the socket file is used by the new process
+		if unixListener, ok := running.listener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+	}
+	e.serverMu.Unlock()
+	debugPrint("Restarted, the new process is %d\n", pid)
+	return nil
+}
+
+// restartEnviron return the environment of the new process without the variables of the old Restart
+func restartEnviron() []string {
+	environ := os.Environ()
+	env := make([]string, 0, len(environ)+2)
+	for _, kv := range environ {
+		if strings.HasPrefix(kv, EnvVexListeners+"=") || strings.HasPrefix(kv, EnvVexReadyFD+"=") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	return env
+}
@@ -0,0 +1,186 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Route is a route registered by the group, it can be named for the reverse url generation
+type Route struct {
+	Method string // the method of route
+	Path   string // the full path of route, the group's name joined with the route's name
+	Host   string // the host pattern of route set by Engine.Host, empty if the route is not bound to a host
+	engine *Engine
+}
+
+// Name set the name of route, so its url can be built by Engine.URL or the "url" template function
+// it panics if the name is used by another route
+//
+//	g.GET("/user/:id", show).Name("user.show")
+func (r *Route) Name(name string) *Route {
+	r.engine.router.mu.Lock()
+	defer r.engine.router.mu.Unlock()
+	if route, ok := r.engine.namedRoutes[name]; ok {
+		panic(fmt.Sprintf("vex: route name '%s' of '%s %s' is used by the route '%s %s'",
+			name, r.Method, r.Path, route.Method, route.Path))
+	}
+	r.engine.namedRoutes[name] = r
+	return r
+}
+
+// URL build the path of the named route, params are the pairs of the param's key and value
+// the values of ":name" and "*" segments are escaped as a segment, the value of "**" keeps its "/"
+// the params not in the route are added as the query string
+//
+//	engine.URL("user.show", "id", 11)            // /user/11
+//	engine.URL("user.show", "id", 11, "tab", 2)  // /user/11?tab=2
+//	{{ url "user.show" "id" .ID }}               // in template
+func (e *Engine) URL(name string, params ...any) (string, error) {
+	e.router.mu.RLock()
+	route, ok := e.namedRoutes[name]
+	constraints := e.constraints
+	e.router.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("vex: route name '%s' is not found", name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("vex: params of route '%s' must be pairs of key and value", name)
+	}
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		key, ok := params[i].(string)
+		if !ok {
+			return "", fmt.Errorf("vex: param key %v of route '%s' is not a string", params[i], name)
+		}
+		values[key] = fmt.Sprint(params[i+1])
+	}
+
+	segments := strings.Split(route.Path, "/")
+	for i, segment := range segments {
+		var key, constraint string
+		switch {
+		case segment == "*" || segment == "**":
+			key = segment
+		case strings.HasPrefix(segment, ":"):
+			key, constraint = splitParam(segment)
+		default:
+			continue
+		}
+		value, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("vex: param '%s' of route '%s' is missing", key, name)
+		}
+		delete(values, key)
+		if constraint != "" {
+			check, err := compileConstraint(constraint, constraints)
+			if err != nil || !check(value) {
+				return "", fmt.Errorf("vex: param '%s' of route '%s' does not match the constraint '%s'", key, name, constraint)
+			}
+		}
+		if segment == "**" {
+			parts := strings.Split(value, "/")
+			for j, part := range parts {
+				parts[j] = url.PathEscape(part)
+			}
+			segments[i] = strings.Join(parts, "/")
+		} else {
+			if value == "" {
+				return "", fmt.Errorf("vex: param '%s' of route '%s' is empty", key, name)
+			}
+			segments[i] = url.PathEscape(value)
+		}
+	}
+	path := strings.Join(segments, "/")
+	if len(values) > 0 {
+		query := make(url.Values, len(values))
+		for key, value := range values {
+			query.Set(key, value)
+		}
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+// RouteInfo represents a request route's specification which contains method, path and its handler.
+type RouteInfo struct {
+	Method      string `json:"method"`         // the method of route, ANY means all the methods
+	Path        string `json:"path"`           // the full path of route
+	Host        string `json:"host,omitempty"` // the host pattern of route
+	Name        string `json:"name,omitempty"` // the name set by Route.Name
+	Handler     string `json:"handler"`        // the function name of handler
+	Middlewares int    `json:"middlewares"`    // the count of middlewares run before the handler, engine's and groups' are included
+}
+
+// Routes returns a slice of registered routes sorted by host, path and method,
+// including some useful information, such as: the http method, path and the handler name.
+func (e *Engine) Routes() []RouteInfo {
+	e.router.mu.RLock()
+	defer e.router.mu.RUnlock()
+	names := make(map[string]string, len(e.namedRoutes))
+	for name, route := range e.namedRoutes {
+		names[route.Host+" "+route.Method+" "+route.Path] = name
+	}
+	routes := make([]RouteInfo, 0)
+	routers := []*router{e.router}
+	for _, h := range e.hosts {
+		routers = append(routers, h.router)
+	}
+	for _, r := range routers {
+		for _, group := range r.routerGroups {
+			for name, methods := range group.handlersChainMap {
+				path := joinPaths(group.name, name)
+				for method, handlers := range methods {
+					routes = append(routes, RouteInfo{
+						Method:      method,
+						Path:        path,
+						Host:        r.host,
+						Name:        names[r.host+" "+method+" "+path],
+						Handler:     nameOfFunction(handlers[len(handlers)-1]),
+						Middlewares: len(handlers) - 1,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Host != routes[j].Host {
+			return routes[i].Host < routes[j].Host
+		}
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// RoutesHandler render the route table in JSON, it can be registered for the tools checking the api
+//
+//	engine.Group("/debug").GET("/routes", engine.RoutesHandler)
+func (e *Engine) RoutesHandler(ctx *Context) {
+	ctx.JSON(http.StatusOK, e.Routes())
+}
+
+// debugPrintRoutes print the route table in debug mode
+func (e *Engine) debugPrintRoutes() {
+	if !IsDebugging() {
+		return
+	}
+	for _, route := range e.Routes() {
+		debugPrint("%-6s %-25s --> %s (%d middlewares)\n", route.Method, route.Host+route.Path, route.Handler, route.Middlewares)
+	}
+}
+
+// nameOfFunction return the full name of function like github.com/axzed/vex.Logger
+func nameOfFunction(f any) string {
+	return runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+}
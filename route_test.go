@@ -0,0 +1,99 @@
+package vex
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"testing"
+)
+
+func TestEngineURL(t *testing.T) {
+	engine := New()
+	g := engine.Group("/api")
+	g.GET("/user/:id<int>", func(ctx *Context) {}).Name("user.show")
+	g.GET("/files/**", func(ctx *Context) {}).Name("files")
+	g.GET("/tag/:name", func(ctx *Context) {}).Name("tag")
+
+	tests := []struct {
+		name   string
+		params []any
+		url    string
+	}{
+		{"user.show", []any{"id", 11}, "/api/user/11"},
+		{"user.show", []any{"id", 11, "tab", "info", "page", 2}, "/api/user/11?page=2&tab=info"},
+		{"files", []any{"**", "docs/a b.md"}, "/api/files/docs/a%20b.md"},
+		{"tag", []any{"name", "c/c++"}, "/api/tag/c%2Fc++"},
+	}
+	for _, test := range tests {
+		u, err := engine.URL(test.name, test.params...)
+		if err != nil || u != test.url {
+			t.Errorf("URL(%s, %v) = %s, %v, want %s", test.name, test.params, u, err, test.url)
+		}
+	}
+	for _, params := range [][]any{{}, {"id", "abc"}, {"id"}} {
+		if _, err := engine.URL("user.show", params...); err == nil {
+			t.Errorf("URL(user.show, %v): expected error", params)
+		}
+	}
+	if _, err := engine.URL("none"); err == nil {
+		t.Error("URL(none): expected error")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic of duplicate route name")
+		}
+	}()
+	g.POST("/user", func(ctx *Context) {}).Name("user.show")
+}
+
+func TestURLTemplateFunc(t *testing.T) {
+	engine := New()
+	engine.SetFuncMap(template.FuncMap{"shout": func(s string) string { return s + "!" }})
+	engine.Group("/").GET("/user/:id", func(ctx *Context) {}).Name("user.show")
+
+	tpl := template.Must(template.New("t").Funcs(engine.funcMap).Parse(`<a href="{{ url "user.show" "id" .ID }}">{{ shout "go" }}</a>`))
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, map[string]any{"ID": 7}); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != `<a href="/user/7">go!</a>` {
+		t.Errorf("template = %s", buf.String())
+	}
+	if w := performRequest(engine, http.MethodGet, "/user/7"); w.Code != http.StatusOK {
+		t.Errorf("/user/7: got %d", w.Code)
+	}
+}
+
+func listUsers(ctx *Context) {}
+
+func TestEngineRoutes(t *testing.T) {
+	engine := New()
+	engine.Use(Logger)
+	api := engine.Group("/api")
+	api.UseHandleFunc(func(ctx *Context) { ctx.Next() })
+	api.GET("/users", listUsers, Recovery).Name("users")
+	api.Group("/v1").POST("/users/:id", listUsers)
+	api.GET("/debug/routes", engine.RoutesHandler)
+
+	want := []RouteInfo{
+		{Method: http.MethodGet, Path: "/api/debug/routes", Handler: "github.com/axzed/vex.(*Engine).RoutesHandler-fm", Middlewares: 2},
+		{Method: http.MethodGet, Path: "/api/users", Name: "users", Handler: "github.com/axzed/vex.listUsers", Middlewares: 3},
+		{Method: http.MethodPost, Path: "/api/v1/users/:id", Handler: "github.com/axzed/vex.listUsers", Middlewares: 2},
+	}
+	routes := engine.Routes()
+	if fmt.Sprint(routes) != fmt.Sprint(want) {
+		t.Errorf("routes = %v, want %v", routes, want)
+	}
+
+	w := performRequest(engine, http.MethodGet, "/api/debug/routes")
+	var got []RouteInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("routes json = %v, want %v", got, want)
+	}
+}
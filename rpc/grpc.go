@@ -49,6 +49,12 @@ func (s *VexGrpcServer) Stop() {
 	s.g.Stop()
 }
 
+// GracefulStop stops the server from accepting new connections and RPCs,
+// and blocks until all the pending RPCs are finished
+func (s *VexGrpcServer) GracefulStop() {
+	s.g.GracefulStop()
+}
+
 func (s *VexGrpcServer) Register(f func(g *grpc.Server)) {
 	s.register = append(s.register, f)
 }
@@ -0,0 +1,359 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+// Run attaches the router to a http.Server and starts listening and serving HTTP requests.
+// the address is ":8080" by default, the server has the timeouts of engine
+// Note: this method will block the calling goroutine until the engine shuts down or an error happens.
+// it returns nil after the engine shuts down gracefully
+func (e *Engine) Run(addr ...string) error {
+	if len(addr) > 1 {
+		return errors.New("too many parameters")
+	}
+	address := ":8080"
+	if len(addr) == 1 {
+		address = addr[0]
+	}
+	e.debugPrintRoutes()
+	debugPrint("Listening and serving HTTP on %s\n", address)
+	listener, err := Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	srv := e.newServer(address)
+	return e.serve(srv, listener, func() error {
+		return srv.Serve(listener)
+	})
+}
+
+// RunTLS attaches the router to a http.Server and starts listening and serving HTTPS (secure) requests.
+// it is a shortcut for RunTLSOptions with the certificate and key files. it blocks as Run
+func (e *Engine) RunTLS(addr, certFile, keyFile string) error {
+	return e.RunTLSOptions(addr, TLSOptions{CertFile: certFile, KeyFile: keyFile})
+}
+
+// RunListener serves HTTP requests on the listener, it blocks as Run
+// the listener created by Listen can be inherited by the new process of Restart
+func (e *Engine) RunListener(listener net.Listener) error {
+	e.debugPrintRoutes()
+	debugPrint("Listening and serving HTTP on listener %s\n", listener.Addr())
+	srv := e.newServer(listener.Addr().String())
+	return e.serve(srv, listener, func() error {
+		return srv.Serve(listener)
+	})
+}
+
+// RunUnix serves HTTP requests on the unix socket file, the file is removed after the server is closed
+// unless it is inherited by the new process of Restart. it blocks as Run
+func (e *Engine) RunUnix(file string) error {
+	e.debugPrintRoutes()
+	debugPrint("Listening and serving HTTP on unix:%s\n", file)
+	listener, err := Listen("unix", file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		e.serverMu.Lock()
+		handedOver := e.handedOver
+		e.serverMu.Unlock()
+		if !handedOver {
+			os.Remove(file)
+		}
+	}()
+	srv := e.newServer(file)
+	return e.serve(srv, listener, func() error {
+		return srv.Serve(listener)
+	})
+}
+
+// RunServer serves HTTP requests by the server you configured, the engine is the handler if the server has none,
+// and it serves h2c if UseH2C is enabled. it serves HTTPS if the TLSConfig of server has the certificates. it blocks as Run, and the server is shut down with the engine
+//
+//	srv := &http.Server{Addr: ":8080", ReadHeaderTimeout: 5 * time.Second}
+//	err := engine.RunServer(srv)
+func (e *Engine) RunServer(srv *http.Server) error {
+	if srv.Handler == nil {
+		srv.Handler = e
+	}
+	useTLS := srv.TLSConfig != nil && (len(srv.TLSConfig.Certificates) > 0 || srv.TLSConfig.GetCertificate != nil)
+	addr := srv.Addr
+	if addr == "" {
+		addr = ":http"
+		if useTLS {
+			addr = ":https"
+		}
+	}
+	e.debugPrintRoutes()
+	debugPrint("Listening and serving HTTP on %s\n", addr)
+	listener, err := Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return e.serve(srv, listener, func() error {
+		if useTLS {
+			return srv.ServeTLS(listener, "", "")
+		}
+		return srv.Serve(listener)
+	})
+}
+
+// Shutdown gracefully shuts down all the running servers of engine without interrupting the in-flight requests,
+// it stops accepting the new connections and waits for the requests done until ctx is done, then runs the OnShutdown hooks.
+// the engine is not Ready as soon as Shutdown is called.
+// the servers can not be started again after Shutdown is called, the blocked Run returns nil after the shutdown is done
+func (e *Engine) Shutdown(ctx context.Context) error {
+	e.serverMu.Lock()
+	if e.shuttingDown {
+		e.serverMu.Unlock()
+		// the shutdown is in process, wait for it
+		select {
+		case <-e.shutdownDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	e.shuttingDown = true
+	// fail the readiness probe at once
+	e.ready.Store(false)
+	servers := make([]*runningServer, 0, len(e.servers))
+	for _, running := range e.servers {
+		servers = append(servers, running)
+	}
+	e.serverMu.Unlock()
+
+	defer close(e.shutdownDone)
+	var wg sync.WaitGroup
+	errs := make([]error, len(servers))
+	for i, running := range servers {
+		wg.Add(1)
+		go func(i int, running *runningServer) {
+			defer wg.Done()
+			errs[i] = running.shutdown(ctx)
+		}(i, running)
+	}
+	wg.Wait()
+	// close the resources after the requests using them are done
+	hookErr := e.runShutdownHooks(ctx)
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return hookErr
+}
+
+// newServer create the http.Server with the timeouts of engine, the h2c is enabled by serve
+func (e *Engine) newServer(addr string) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           e,
+		ReadTimeout:       e.ReadTimeout,
+		ReadHeaderTimeout: e.ReadHeaderTimeout,
+		WriteTimeout:      e.WriteTimeout,
+		IdleTimeout:       e.IdleTimeout,
+	}
+}
+
+// runningServer is a server started by the engine
+type runningServer struct {
+	srv      *http.Server
+	listener net.Listener
+	newConns sync.Map       // the connections whose first request is not read yet, and their accepted time
+	h2cConns sync.WaitGroup // the h2c connections hijacked from srv, http.Server.Shutdown does not wait for them
+}
+
+// serveH2C serve h2c by the HTTP/2 server configured on srv, so the h2c connections get GOAWAY
+// when srv is shut down, and the shutdown waits for their streams as the HTTP/1 connections
+func (s *runningServer) serveH2C() error {
+	h2s := &http2.Server{IdleTimeout: s.srv.IdleTimeout}
+	if err := http2.ConfigureServer(s.srv, h2s); err != nil {
+		return err
+	}
+	handler := h2c.NewHandler(s.srv.Handler, h2s)
+	s.srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the h2c connection is served in the handler of its first request until it is closed
+		s.h2cConns.Add(1)
+		defer s.h2cConns.Done()
+		handler.ServeHTTP(w, r)
+	})
+	return nil
+}
+
+// trackConns record the new connections by the ConnState hook of server, the hook set by you is still called
+func (s *runningServer) trackConns() {
+	connState := s.srv.ConnState
+	s.srv.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			s.newConns.Store(conn, time.Now())
+		} else {
+			s.newConns.Delete(conn)
+		}
+		if connState != nil {
+			connState(conn, state)
+		}
+	}
+}
+
+// shutdown stop accepting and wait for the accepted connections sending their first request before http.Server.Shutdown,
+// because http.Server drops the request read after the shutdown starts, like the one just accepted.
+// the connection not sending request in 5 seconds is closed as idle
+func (s *runningServer) shutdown(ctx context.Context) error {
+	s.listener.Close()
+	s.srv.SetKeepAlivesEnabled(false)
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for s.waitingRequest() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	if err := s.srv.Shutdown(ctx); err != nil {
+		return err
+	}
+	// no HTTP/1 request is in flight now, the rest are the h2c connections finishing their streams after GOAWAY
+	done := make(chan struct{})
+	go func() {
+		s.h2cConns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// waitingRequest check there is a connection accepted in 5 seconds and not sending request yet
+func (s *runningServer) waitingRequest() bool {
+	waiting := false
+	s.newConns.Range(func(_, accepted any) bool {
+		waiting = time.Since(accepted.(time.Time)) < 5*time.Second
+		return !waiting
+	})
+	return waiting
+}
+
+// serve run the server on the listener until it is closed, it waits for the shutdown of engine is done before it returns
+func (e *Engine) serve(srv *http.Server, listener net.Listener, serve func() error) error {
+	running := &runningServer{srv: srv, listener: listener}
+	if e.UseH2C && srv.Handler == http.Handler(e) {
+		if err := running.serveH2C(); err != nil {
+			listener.Close()
+			return err
+		}
+	}
+	e.serverMu.Lock()
+	if e.shuttingDown {
+		e.serverMu.Unlock()
+		listener.Close()
+		return http.ErrServerClosed
+	}
+	running.trackConns()
+	e.servers[srv] = running
+	e.serverMu.Unlock()
+	if err := e.start(); err != nil {
+		e.serverMu.Lock()
+		delete(e.servers, srv)
+		e.serverMu.Unlock()
+		listener.Close()
+		return err
+	}
+	defer func() {
+		e.serverMu.Lock()
+		delete(e.servers, srv)
+		e.serverMu.Unlock()
+		listenerKeys.Delete(listener)
+	}()
+	e.watchSignals()
+	// the process started by Restart is ready when it is serving, the old process begins to shut down
+	notifyReady()
+
+	err := serve()
+	e.serverMu.Lock()
+	shuttingDown := e.shuttingDown
+	e.serverMu.Unlock()
+	// the listener is closed by the shutdown of engine before http.Server is shut down
+	if !errors.Is(err, http.ErrServerClosed) && !(shuttingDown && errors.Is(err, net.ErrClosed)) {
+		return err
+	}
+	if shuttingDown {
+		// http.Server returns as soon as the shutdown starts, wait for the in-flight requests
+		<-e.shutdownDone
+	}
+	return nil
+}
+
+// watchSignals shut down the engine when one of ShutdownSignals is received,
+// or restart the process and shut down when one of RestartSignals is received.
+// it is started once by the first server
+func (e *Engine) watchSignals() {
+	if len(e.ShutdownSignals) == 0 && len(e.RestartSignals) == 0 {
+		return
+	}
+	e.signalOnce.Do(func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, append(append([]os.Signal(nil), e.ShutdownSignals...), e.RestartSignals...)...)
+		go func() {
+			defer signal.Stop(ch)
+			for {
+				select {
+				case sig := <-ch:
+					if containsSignal(e.RestartSignals, sig) {
+						debugPrint("Restarting on signal %v\n", sig)
+						if err := e.Restart(); err != nil {
+							// the old process keeps serving if the new one fails
+							e.Logger.Error(err)
+							continue
+						}
+					}
+					debugPrint("Shutting down on signal %v\n", sig)
+					e.shutdownWithTimeout()
+					return
+				case <-e.shutdownDone:
+					return
+				}
+			}
+		}()
+	})
+}
+
+// shutdownWithTimeout shut down the engine waiting for ShutdownTimeout at most
+func (e *Engine) shutdownWithTimeout() {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if e.ShutdownTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, e.ShutdownTimeout)
+	}
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		e.Logger.Error(err)
+	}
+}
+
+// containsSignal check the signal is in the list
+func containsSignal(signals []os.Signal, sig os.Signal) bool {
+	for _, s := range signals {
+		if s == sig {
+			return true
+		}
+	}
+	return false
+}
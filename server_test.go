@@ -0,0 +1,142 @@
+package vex
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startEngine run the engine on a local listener and return its url and the result of RunListener
+func startEngine(t *testing.T, engine *Engine) (string, <-chan error) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- engine.RunListener(listener)
+	}()
+	return "http://" + listener.Addr().String(), done
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	engine := New()
+	engine.ShutdownSignals = nil
+	started := make(chan struct{})
+	engine.Group("/").GET("/slow", func(ctx *Context) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		ctx.String(http.StatusOK, "done")
+	})
+	url, done := startEngine(t, engine)
+
+	result := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if err != nil {
+			result <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		result <- string(body)
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := engine.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown error: %v", err)
+	}
+	if body := <-result; body != "done" {
+		t.Errorf("in-flight request got %q, want %q", body, "done")
+	}
+	if err := <-done; err != nil {
+		t.Errorf("RunListener returned %v, want nil", err)
+	}
+	if _, err := http.Get(url + "/slow"); err == nil {
+		t.Errorf("request after Shutdown should fail")
+	}
+	if err := engine.Run("127.0.0.1:0"); err != http.ErrServerClosed {
+		t.Errorf("Run after Shutdown returned %v, want %v", err, http.ErrServerClosed)
+	}
+	if err := engine.Shutdown(ctx); err != nil {
+		t.Errorf("the second Shutdown returned %v", err)
+	}
+}
+
+func TestRunMultipleServers(t *testing.T) {
+	engine := New()
+	engine.ShutdownSignals = nil
+	engine.Group("/").GET("/ping", func(ctx *Context) {
+		ctx.String(http.StatusOK, "pong")
+	})
+	url1, done1 := startEngine(t, engine)
+	url2, done2 := startEngine(t, engine)
+
+	socket := filepath.Join(t.TempDir(), "vex.sock")
+	done3 := make(chan error, 1)
+	go func() {
+		done3 <- engine.RunUnix(socket)
+	}()
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	get := func(client *http.Client, url string) {
+		t.Helper()
+		var resp *http.Response
+		var err error
+		// the server may not be listening yet
+		for i := 0; i < 50; i++ {
+			if resp, err = client.Get(url); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		defer resp.Body.Close()
+		if body, _ := io.ReadAll(resp.Body); string(body) != "pong" {
+			t.Errorf("GET %s body = %q, want %q", url, body, "pong")
+		}
+	}
+	get(http.DefaultClient, url1+"/ping")
+	get(http.DefaultClient, url2+"/ping")
+	get(unixClient, "http://unix/ping")
+
+	if err := engine.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown error: %v", err)
+	}
+	for _, done := range []<-chan error{done1, done2, done3} {
+		if err := <-done; err != nil {
+			t.Errorf("run returned %v, want nil", err)
+		}
+	}
+	if _, err := os.Stat(socket); !os.IsNotExist(err) {
+		t.Errorf("the socket file should be removed, stat error: %v", err)
+	}
+}
+
+func TestServerTimeouts(t *testing.T) {
+	engine := New()
+	engine.ReadTimeout = time.Second
+	engine.ReadHeaderTimeout = 2 * time.Second
+	engine.WriteTimeout = 3 * time.Second
+	engine.IdleTimeout = 4 * time.Second
+	srv := engine.newServer(":0")
+	if srv.Handler != engine || srv.ReadTimeout != time.Second || srv.ReadHeaderTimeout != 2*time.Second ||
+		srv.WriteTimeout != 3*time.Second || srv.IdleTimeout != 4*time.Second {
+		t.Errorf("server is not configured by engine: %+v", srv)
+	}
+}
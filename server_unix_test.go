@@ -0,0 +1,30 @@
+//go:build unix
+
+package vex
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestShutdownOnSignal(t *testing.T) {
+	engine := New()
+	engine.ShutdownSignals = []os.Signal{syscall.SIGUSR1}
+	engine.ShutdownTimeout = time.Second
+	_, done := startEngine(t, engine)
+	// wait for the signal watcher
+	time.Sleep(50 * time.Millisecond)
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("RunListener returned %v, want nil", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("engine is not shut down on the signal")
+	}
+}
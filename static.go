@@ -0,0 +1,141 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// fileSystem is the http.FileSystem with the control of directory listing
+// if root is set, the file linked out of root is not served
+type fileSystem struct {
+	http.FileSystem
+	root          string
+	listDirectory bool
+}
+
+// Dir returns a http.FileSystem serving the files in root like http.Dir,
+// the symbolic link pointing out of root is not followed, and the directory is listed only if listDirectory is true
+func Dir(root string, listDirectory bool) http.FileSystem {
+	return &fileSystem{FileSystem: http.Dir(root), root: root, listDirectory: listDirectory}
+}
+
+// FS returns a http.FileSystem serving the files in fsys like embed.FS,
+// the directory is listed only if listDirectory is true
+//
+//	//go:embed dist
+//	var dist embed.FS
+//	sub, _ := fs.Sub(dist, "dist")
+//	g.StaticFS("/", vex.FS(sub, false))
+func FS(fsys fs.FS, listDirectory bool) http.FileSystem {
+	return &fileSystem{FileSystem: http.FS(fsys), listDirectory: listDirectory}
+}
+
+// Open opens the file like the wrapped http.FileSystem and checks the real path of file is in root
+func (f *fileSystem) Open(name string) (http.File, error) {
+	file, err := f.FileSystem.Open(name)
+	if err != nil || f.root == "" {
+		return file, err
+	}
+	root, err := filepath.EvalSymlinks(f.root)
+	if err == nil {
+		var real string
+		real, err = filepath.EvalSymlinks(filepath.Join(f.root, filepath.FromSlash(path.Clean("/"+name))))
+		if err == nil && real != root && !strings.HasPrefix(real, root+string(filepath.Separator)) {
+			err = os.ErrNotExist
+		}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// StaticFile registers a single route in order to serve a single file of the local filesystem.
+//
+//	g.StaticFile("/favicon.ico", "./resources/favicon.ico")
+func (r *routerGroup) StaticFile(relativePath, file string) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic(fmt.Sprintf("vex: URL parameters can not be used when serving a static file: %s", relativePath))
+	}
+	r.GET(relativePath, func(ctx *Context) {
+		ctx.File(file)
+	})
+}
+
+// Static serves files from the given file system root, the directory is not listed.
+// Internally a http.FileServer is used, so the Range, If-Modified-Since and index.html are handled,
+// and the ETag of the file is set to answer If-None-Match if the file has the modification time.
+//
+//	g.Static("/static", "/var/www")
+func (r *routerGroup) Static(relativePath, root string) {
+	r.StaticFS(relativePath, Dir(root, false))
+}
+
+// StaticFS works just like `Static()` but a custom `http.FileSystem` can be used instead.
+// the directory is listed only if fs is created by Dir or FS with listDirectory,
+// the files not found are answered by the NoRoute handlers
+func (r *routerGroup) StaticFS(relativePath string, fs http.FileSystem) {
+	if strings.ContainsAny(relativePath, ":*") {
+		panic(fmt.Sprintf("vex: URL parameters can not be used when serving a static folder: %s", relativePath))
+	}
+	r.GET(joinPaths(relativePath, "/**"), r.createStaticHandler(fs))
+}
+
+// createStaticHandler serves the file named by the "**" param in fs
+func (r *routerGroup) createStaticHandler(fs http.FileSystem) HandleFunc {
+	fileServer := http.FileServer(fs)
+	listDirectory := false
+	if f, ok := fs.(*fileSystem); ok {
+		listDirectory = f.listDirectory
+	}
+	return func(ctx *Context) {
+		name := "/" + ctx.Param("**")
+		stat, ok := statFile(fs, path.Clean(name))
+		if !ok || stat.IsDir() && !listDirectory {
+			r.router.engine.serveNotFound(ctx)
+			return
+		}
+		// the files without the modification time like the ones of embed.FS have no ETag,
+		// the size alone can not tell the content is changed
+		if !stat.IsDir() && !stat.ModTime().IsZero() {
+			ctx.W.Header().Set("ETag", fmt.Sprintf(`W/"%x-%x"`, stat.ModTime().UnixNano(), stat.Size()))
+		}
+		// the file server gets the path without the prefix of route
+		req := new(http.Request)
+		*req = *ctx.R
+		u := *ctx.R.URL
+		u.Path = name
+		u.RawPath = ""
+		req.URL = &u
+		fileServer.ServeHTTP(ctx.W, req)
+	}
+}
+
+// statFile stat the file in fs, the index.html is used for the directory if it exists
+func statFile(fs http.FileSystem, name string) (os.FileInfo, bool) {
+	f, err := fs.Open(name)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, false
+	}
+	if stat.IsDir() {
+		if index, ok := statFile(fs, path.Join(name, "index.html")); ok && !index.IsDir() {
+			return index, true
+		}
+	}
+	return stat, true
+}
@@ -0,0 +1,122 @@
+package vex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestStatic(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "public")
+	os.MkdirAll(filepath.Join(root, "docs"), 0755)
+	os.MkdirAll(filepath.Join(root, "app"), 0755)
+	os.WriteFile(filepath.Join(root, "hello.txt"), []byte("hello vex"), 0644)
+	os.WriteFile(filepath.Join(root, "app", "index.html"), []byte("<h1>app</h1>"), 0644)
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0644)
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := New()
+	g := engine.Group("/")
+	g.Static("/static", root)
+	g.StaticFS("/list", Dir(root, true))
+	g.StaticFile("/hello", filepath.Join(root, "hello.txt"))
+
+	w := performRequest(engine, http.MethodGet, "/static/hello.txt")
+	if w.Code != http.StatusOK || w.Body.String() != "hello vex" {
+		t.Fatalf("/static/hello.txt: got %d %q", w.Code, w.Body.String())
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") == "" {
+		t.Errorf("/static/hello.txt: ETag %q Last-Modified %q", etag, w.Header().Get("Last-Modified"))
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/static/hello.txt", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match: got %d, want 304", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/static/hello.txt", nil)
+	req.Header.Set("Range", "bytes=6-")
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusPartialContent || w.Body.String() != "vex" {
+		t.Errorf("Range: got %d %q", w.Code, w.Body.String())
+	}
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/static/app/", http.StatusOK, "<h1>app</h1>"},
+		{"/static/docs/", http.StatusNotFound, ""},
+		{"/static/none.txt", http.StatusNotFound, ""},
+		{"/static/link.txt", http.StatusNotFound, ""},
+		{"/static/%2e%2e/secret.txt", http.StatusNotFound, ""},
+		{"/list/docs/", http.StatusOK, ""},
+		{"/hello", http.StatusOK, "hello vex"},
+	}
+	for _, test := range tests {
+		w := performRequest(engine, http.MethodGet, test.path)
+		if w.Code != test.code || test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s: got %d %q, want %d %q", test.path, w.Code, w.Body.String(), test.code, test.body)
+		}
+	}
+}
+
+func TestStaticFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":    {Data: []byte("<h1>spa</h1>"), ModTime: time.Now()},
+		"assets/app.js": {Data: []byte("console.log('vex')"), ModTime: time.Now()},
+	}
+	engine := New()
+	engine.Group("/admin").StaticFS("/", FS(fsys, false))
+
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/admin/", http.StatusOK, "<h1>spa</h1>"},
+		{"/admin/assets/app.js", http.StatusOK, "console.log('vex')"},
+		{"/admin/assets/", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := performRequest(engine, http.MethodGet, test.path)
+		if w.Code != test.code || test.body != "" && w.Body.String() != test.body {
+			t.Errorf("%s: got %d %q, want %d %q", test.path, w.Code, w.Body.String(), test.code, test.body)
+		}
+	}
+}
+
+func TestStaticFSZeroModTime(t *testing.T) {
+	// the files of embed.FS have no modification time
+	fsys := fstest.MapFS{"app.js": {Data: []byte("v1")}}
+	engine := New()
+	engine.Group("/").StaticFS("/", FS(fsys, false))
+
+	w := performRequest(engine, http.MethodGet, "/app.js")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag != "" {
+		t.Errorf("got %d with ETag %q, want 200 without ETag", w.Code, etag)
+	}
+	// the content is changed to the same size
+	fsys["app.js"] = &fstest.MapFile{Data: []byte("v2")}
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("If-None-Match", `W/"-5e4dfc14c2e60000-2"`)
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != "v2" {
+		t.Errorf("changed file got %d %q, want 200 %q", w.Code, w.Body.String(), "v2")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timeout is a middleware canceling the rest of chain after the timeout, the request's context of handlers is done
+// at the timeout and the client gets 503 Service Unavailable.
+// the rest of chain runs in another goroutine with a copy of Context, its response is buffered and written
+// when it returns in time, the writes after the timeout are discarded with http.ErrHandlerTimeout.
+// the panic of handlers is raised again in the request's goroutine, so it is handled by Recovery before Timeout
+//
+//	g.Use(vex.Timeout(3 * time.Second))
+//	g.GET("/report", func(ctx *vex.Context) {
+//	    rows, err := db.QueryContext(ctx, "SELECT ...") // canceled at the timeout
+//	})
+func Timeout(timeout time.Duration) MiddlewareFunc {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			timeoutCtx, cancel := context.WithTimeout(ctx.R.Context(), timeout)
+			defer cancel()
+			tw := &timeoutWriter{header: make(http.Header)}
+			child := ctx.clone()
+			child.W = newResponseWriter(tw)
+			child.R = ctx.R.WithContext(timeoutCtx)
+
+			done := make(chan struct{})
+			panicChan := make(chan any, 1)
+			go func() {
+				defer func() {
+					if p := recover(); p != nil {
+						panicChan <- p
+					}
+				}()
+				next(child)
+				close(done)
+			}()
+
+			select {
+			case p := <-panicChan:
+				// the rest of chain is done with the panic, it must not run again after Recovery
+				ctx.Abort()
+				panic(p)
+			case <-done:
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				// the chain is done in time, take its state back and write the buffered response
+				ctx.index = child.index
+				ctx.StatusCode = child.StatusCode
+				ctx.mu.Lock()
+				ctx.Keys = child.Keys
+				ctx.mu.Unlock()
+				dst := ctx.W.Header()
+				for k, v := range tw.header {
+					dst[k] = v
+				}
+				if tw.code == 0 {
+					tw.code = http.StatusOK
+				}
+				ctx.W.WriteHeader(tw.code)
+				ctx.W.Write(tw.buf.Bytes())
+			case <-timeoutCtx.Done():
+				tw.mu.Lock()
+				defer tw.mu.Unlock()
+				tw.timedOut = true
+				ctx.Abort()
+				ctx.String(http.StatusServiceUnavailable, "%s\n", http.StatusText(http.StatusServiceUnavailable))
+			}
+		}
+	}
+}
+
+// timeoutWriter buffers the response of handlers running with Timeout
+type timeoutWriter struct {
+	header   http.Header
+	buf      bytes.Buffer
+	mu       sync.Mutex
+	code     int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.code == 0 {
+		tw.code = http.StatusOK
+	}
+	return tw.buf.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.code != 0 {
+		return
+	}
+	tw.code = code
+}
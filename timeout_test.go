@@ -0,0 +1,73 @@
+package vex
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	engine := New()
+	engine.Use(Recovery)
+	g := engine.Group("/")
+	g.Use(Timeout(50 * time.Millisecond))
+	g.UseHandleFunc(func(ctx *Context) {
+		ctx.Set("middleware", "ok")
+		ctx.Next()
+	})
+	g.GET("/fast", func(ctx *Context) {
+		ctx.W.Header().Set("X-Fast", "1")
+		ctx.String(http.StatusCreated, "fast %v", ctx.Value("middleware"))
+	})
+	canceled := make(chan error, 1)
+	g.GET("/slow", func(ctx *Context) {
+		select {
+		case <-ctx.Done():
+			canceled <- ctx.Err()
+		case <-time.After(time.Second):
+			canceled <- nil
+		}
+	})
+	late := make(chan error, 1)
+	g.GET("/ignore", func(ctx *Context) {
+		time.Sleep(100 * time.Millisecond)
+		// the context of request may be used by another request now, the copy is safe to use
+		ctx.Set("late", true)
+		_, err := ctx.W.Write([]byte("late"))
+		late <- err
+	})
+	g.GET("/panic", func(ctx *Context) {
+		panic(context.Canceled)
+	})
+
+	w := performRequest(engine, http.MethodGet, "/fast")
+	if w.Code != http.StatusCreated || w.Body.String() != "fast ok" || w.Header().Get("X-Fast") != "1" {
+		t.Errorf("fast handler got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+
+	w = performRequest(engine, http.MethodGet, "/slow")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("slow handler got %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if err := <-canceled; err != context.DeadlineExceeded {
+		t.Errorf("the context of slow handler got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	w = performRequest(engine, http.MethodGet, "/ignore")
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("handler ignoring the context got %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	// the pooled context is reused by the other requests while the handler is running
+	for i := 0; i < 10; i++ {
+		performRequest(engine, http.MethodGet, "/fast")
+	}
+	if err := <-late; err != http.ErrHandlerTimeout {
+		t.Errorf("write after the timeout got %v, want %v", err, http.ErrHandlerTimeout)
+	}
+
+	w = performRequest(engine, http.MethodGet, "/panic")
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("panic handler got %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
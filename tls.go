@@ -0,0 +1,173 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSOptions configures the HTTPS server of RunTLSOptions and the tls.Config of NewTLSConfig
+type TLSOptions struct {
+	CertFile string // the certificate file in PEM, it can contain the intermediate certificates after the leaf
+	KeyFile  string // the private key file in PEM
+	// ReloadInterval is the interval checking the modification of CertFile and KeyFile, the certificate is reloaded
+	// for the new connections if any of them is changed. zero means the certificate is never reloaded
+	ReloadInterval time.Duration
+
+	// ClientCAFile is the CA certificates in PEM verifying the client certificates, it enables mTLS,
+	// the verified client certificate is returned by Context.ClientCertificate
+	ClientCAFile string
+	// ClientAuth is the policy of client certificate, default is tls.RequireAndVerifyClientCert if ClientCAFile is set
+	ClientAuth tls.ClientAuthType
+
+	// MinVersion is the minimum TLS version, default is tls.VersionTLS12
+	MinVersion uint16
+	// CipherSuites is the enabled cipher suites of TLS 1.0-1.2, nil means the default of crypto/tls.
+	// the cipher suites of TLS 1.3 are not configurable
+	CipherSuites []uint16
+}
+
+// RunTLSOptions attaches the router to a http.Server and starts listening and serving HTTPS (secure) requests
+// with the options of TLS. it blocks as Run
+//
+//	err := engine.RunTLSOptions(":443", vex.TLSOptions{
+//	    CertFile:       "server.crt",
+//	    KeyFile:        "server.key",
+//	    ClientCAFile:   "ca.crt",
+//	    ReloadInterval: time.Minute,
+//	})
+func (e *Engine) RunTLSOptions(addr string, options TLSOptions) error {
+	config, err := e.NewTLSConfig(options)
+	if err != nil {
+		return err
+	}
+	e.debugPrintRoutes()
+	debugPrint("Listening and serving HTTPS on %s\n", addr)
+	listener, err := Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	srv := e.newServer(addr)
+	srv.TLSConfig = config
+	return e.serve(srv, listener, func() error {
+		return srv.ServeTLS(listener, "", "")
+	})
+}
+
+// NewTLSConfig build the tls.Config by the options, it can be used by the server of RunServer
+// the certificate is loaded by GetCertificate, the error of reloading is logged by the Logger of engine,
+// and the last loaded certificate is used until the files are fixed
+func (e *Engine) NewTLSConfig(options TLSOptions) (*tls.Config, error) {
+	if options.CertFile == "" || options.KeyFile == "" {
+		return nil, errors.New("vex: the certificate and key files of TLS are required")
+	}
+	reloader := &certReloader{
+		certFile: options.CertFile,
+		keyFile:  options.KeyFile,
+		interval: options.ReloadInterval,
+		engine:   e,
+	}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		GetCertificate: reloader.getCertificate,
+		MinVersion:     options.MinVersion,
+		CipherSuites:   options.CipherSuites,
+		ClientAuth:     options.ClientAuth,
+	}
+	if config.MinVersion == 0 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	if options.ClientCAFile != "" {
+		pem, err := os.ReadFile(options.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("vex: no certificate is found in the client CA file %s", options.ClientCAFile)
+		}
+		if config.ClientAuth == tls.NoClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+	return config, nil
+}
+
+// certReloader load the certificate again when its files are modified
+type certReloader struct {
+	certFile string
+	keyFile  string
+	interval time.Duration
+	engine   *Engine
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time // the latest modification time of the files
+	checked time.Time // the time of last check
+}
+
+// getCertificate is the GetCertificate of tls.Config, the files are checked at most once in the interval
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.interval > 0 && time.Since(r.checked) >= r.interval {
+		r.checked = time.Now()
+		if modTime, err := r.latestModTime(); err != nil || !modTime.Equal(r.modTime) {
+			if err == nil {
+				err = r.reloadLocked()
+			}
+			if err != nil {
+				r.engine.Logger.Error(fmt.Sprintf("vex: reload the certificate %s: %v", r.certFile, err))
+			}
+		}
+	}
+	return r.cert, nil
+}
+
+// reload load the certificate and key files
+func (r *certReloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checked = time.Now()
+	return r.reloadLocked()
+}
+
+// reloadLocked load the files, the caller must hold the lock
+func (r *certReloader) reloadLocked() error {
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.modTime = modTime
+	return nil
+}
+
+// latestModTime return the latest modification time of the certificate and key files
+func (r *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
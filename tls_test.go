@@ -0,0 +1,320 @@
+package vex
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// testCert is a certificate generated for the test
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newTestCert create the certificate signed by parent, it is self-signed if parent is nil
+func newTestCert(t *testing.T, name string, parent *testCert, isCA bool) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCert{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// write save the certificate and key files in dir
+func (c *testCert) write(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	keyDer, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := os.WriteFile(certFile, c.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// tlsCertificate return the certificate used by the client
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.cert.Raw}, PrivateKey: c.key}
+}
+
+// startTLSEngine run the engine with the TLS options on a local listener
+func startTLSEngine(t *testing.T, engine *Engine, options TLSOptions) string {
+	t.Helper()
+	config, err := engine.NewTLSConfig(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := engine.newServer(listener.Addr().String())
+	srv.TLSConfig = config
+	// the failed handshakes are expected
+	srv.ErrorLog = log.New(io.Discard, "", 0)
+	go engine.serve(srv, listener, func() error {
+		return srv.ServeTLS(listener, "", "")
+	})
+	t.Cleanup(func() {
+		engine.Shutdown(context.Background())
+	})
+	return "https://" + listener.Addr().String()
+}
+
+func tlsGet(client *http.Client, url string) (string, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestTLSClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test ca", nil, true)
+	server := newTestCert(t, "server", ca, false)
+	client := newTestCert(t, "alice", ca, false)
+	stranger := newTestCert(t, "mallory", nil, false)
+	certFile, keyFile := server.write(t, dir, "server")
+	caFile := filepath.Join(dir, "ca.crt")
+	if err := os.WriteFile(caFile, ca.pem, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := New()
+	engine.ShutdownSignals = nil
+	engine.Group("/").GET("/whoami", func(ctx *Context) {
+		cert := ctx.ClientCertificate()
+		if cert == nil {
+			ctx.String(http.StatusOK, "anonymous")
+			return
+		}
+		ctx.String(http.StatusOK, cert.Subject.CommonName)
+	})
+	url := startTLSEngine(t, engine, TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	newClient := func(certs ...tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+	}
+
+	if body, err := tlsGet(newClient(client.tlsCertificate()), url+"/whoami"); err != nil || body != "alice" {
+		t.Errorf("client certificate: got %q, %v, want %q", body, err, "alice")
+	}
+	if _, err := tlsGet(newClient(), url+"/whoami"); err == nil {
+		t.Errorf("request without the client certificate should fail")
+	}
+	if _, err := tlsGet(newClient(stranger.tlsCertificate()), url+"/whoami"); err == nil {
+		t.Errorf("request with the certificate of unknown CA should fail")
+	}
+}
+
+func TestTLSMinVersionAndCiphers(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test ca", nil, true)
+	certFile, keyFile := newTestCert(t, "server", ca, false).write(t, dir, "server")
+	engine := New()
+	engine.ShutdownSignals = nil
+	engine.Group("/").GET("/", func(ctx *Context) {
+		ctx.String(http.StatusOK, "%x", ctx.R.TLS.CipherSuite)
+	})
+	cipher := tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256
+	url := startTLSEngine(t, engine, TLSOptions{
+		CertFile:     certFile,
+		KeyFile:      keyFile,
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{cipher},
+	})
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	old := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs: roots, MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS11,
+	}}}
+	if _, err := tlsGet(old, url); err == nil {
+		t.Errorf("request of TLS 1.1 should fail")
+	}
+	tls12 := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs: roots, MaxVersion: tls.VersionTLS12,
+	}}}
+	if body, err := tlsGet(tls12, url); err != nil || body != fmt.Sprintf("%x", cipher) {
+		t.Errorf("cipher suite: got %q, %v, want %x", body, err, cipher)
+	}
+}
+
+func TestTLSCertificateReload(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCert(t, "test ca", nil, true)
+	certFile, keyFile := newTestCert(t, "first", ca, false).write(t, dir, "server")
+	engine := New()
+	engine.ShutdownSignals = nil
+	engine.Logger.Outs = nil
+	engine.Group("/").GET("/", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+	url := startTLSEngine(t, engine, TLSOptions{CertFile: certFile, KeyFile: keyFile, ReloadInterval: time.Millisecond})
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	serverName := func() string {
+		t.Helper()
+		// a new connection for each request, so the certificate of handshake is the current one
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}, DisableKeepAlives: true}}
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	if name := serverName(); name != "first" {
+		t.Errorf("certificate = %s, want first", name)
+	}
+
+	// the broken file keeps the last certificate
+	if err := os.WriteFile(certFile, []byte("broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if name := serverName(); name != "first" {
+		t.Errorf("certificate = %s after the file is broken, want first", name)
+	}
+
+	newTestCert(t, "second", ca, false).write(t, dir, "server")
+	// make sure the modification time is changed on the file system with the coarse time
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	time.Sleep(5 * time.Millisecond)
+	if name := serverName(); name != "second" {
+		t.Errorf("certificate = %s after reload, want second", name)
+	}
+}
+
+func TestH2C(t *testing.T) {
+	engine := New()
+	engine.ShutdownSignals = nil
+	engine.UseH2C = true
+	engine.Group("/").GET("/proto", func(ctx *Context) {
+		ctx.String(http.StatusOK, ctx.R.Proto)
+	})
+	url, _ := startEngine(t, engine)
+	defer engine.Shutdown(context.Background())
+
+	h2 := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	var body string
+	var err error
+	for i := 0; i < 50; i++ {
+		if body, err = tlsGet(h2, url+"/proto"); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil || body != "HTTP/2.0" {
+		t.Errorf("h2c: got %q, %v, want HTTP/2.0", body, err)
+	}
+	if body, err = tlsGet(http.DefaultClient, url+"/proto"); err != nil || body != "HTTP/1.1" {
+		t.Errorf("http/1: got %q, %v, want HTTP/1.1", body, err)
+	}
+}
+
+func TestH2CShutdownDrainsStreams(t *testing.T) {
+	engine := New()
+	engine.ShutdownSignals = nil
+	engine.UseH2C = true
+	started := make(chan struct{})
+	var finished atomic.Bool
+	engine.Group("/").GET("/slow", func(ctx *Context) {
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		finished.Store(true)
+		ctx.String(http.StatusOK, ctx.R.Proto)
+	})
+	url, done := startEngine(t, engine)
+
+	h2 := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+			return net.Dial(network, addr)
+		},
+	}}
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		body, err := tlsGet(h2, url+"/slow")
+		results <- result{body, err}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := engine.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown error: %v", err)
+	}
+	if !finished.Load() {
+		t.Errorf("Shutdown returned before the h2c stream is done")
+	}
+	if r := <-results; r.err != nil || r.body != "HTTP/2.0" {
+		t.Errorf("in-flight h2c request got %q, %v, want HTTP/2.0", r.body, r.err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("RunListener returned %v, want nil", err)
+	}
+}
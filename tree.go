@@ -4,83 +4,469 @@
 
 package vex
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
+// Param is a single URL parameter, consisting of a key and a value.
+type Param struct {
+	Key   string
+	Value string
+}
+
+// Params is a Param-slice, as returned by the router.
+// The slice is ordered, the first URL parameter is also the first slice value.
+type Params []Param
+
+// Get returns the value of the first Param which key matches the given name and a boolean true.
+// If no matching Param is found, an empty string is returned and a boolean false .
+func (ps Params) Get(name string) (string, bool) {
+	for _, entry := range ps {
+		if entry.Key == name {
+			return entry.Value, true
+		}
+	}
+	return "", false
+}
+
+// ByName returns the value of the first Param which key matches the given name.
+// If no matching Param is found, an empty string is returned.
+func (ps Params) ByName(name string) (va string) {
+	va, _ = ps.Get(name)
+	return
+}
+
+// nodeKind is the kind of tree node, the lower kind has the higher match priority
+type nodeKind uint8
+
+const (
+	staticKind   nodeKind = iota // static part of url like /user/
+	paramKind                    // :id match one segment
+	wildcardKind                 // * match one segment
+	catchAllKind                 // ** match the rest of url
+)
+
+// treeNode is the node of a compressed radix tree
+// the static part of urls share the common prefix in one node,
+// and the dynamic segments (:id, *, **) are stored in their own child
 type treeNode struct {
-	name       string
-	children   []*treeNode
-	routerName string
-	isEnd      bool
+	name          string             // static: the compressed prefix | dynamic: ":id", "*" or "**"
+	kind          nodeKind           // the kind of node
+	children      map[byte]*treeNode // static children indexed by their first byte
+	paramChildren []*treeNode        // children match :id, the ones with constraint like :id<int> are tried first
+	wildcardChild *treeNode          // child matches *
+	catchAllChild *treeNode          // child matches **
+	routerName    string             // the route registered on this node
+	owner         string             // the first route passes through this dynamic node, used by the conflict message
+	constraint    ParamConstraint    // the constraint of param node, nil means any value
+	isEnd         bool               // a route ends at this node
 }
 
 // put path: /user/get/:id
-func (t *treeNode) Put(path string) {
-	root := t
-	strs := strings.Split(path, "/")
-	for index, name := range strs {
-		// ignore the prefix "_" before the first "/"
-		if index == 0 {
+// the param can have a constraint like :id<int>, the constraint is found in constraints, the builtin constraints,
+// or compiled as a regular expression like :name<[a-z]+>
+// it returns an error if the route is invalid or conflicts with the routes in the tree:
+// a different param name with the same constraint at the same position, "*" next to a param or "**" in the middle of route.
+// the whole route is checked before any node is added, so the tree is not changed by a failed route
+func (t *treeNode) Put(path string, constraints map[string]ParamConstraint) error {
+	routerName := path
+	path, compiled, err := t.checkPut(path, constraints)
+	if err != nil {
+		return err
+	}
+	node := t
+	for path != "" {
+		segment, rest, dynamic := nextSegment(path)
+		if dynamic {
+			node = node.putDynamic(segment, routerName, compiled[segment])
+		} else {
+			node = node.putStatic(segment)
+		}
+		path = rest
+	}
+	node.isEnd = true
+	node.routerName = routerName
+	return nil
+}
+
+// checkPut check the route can be put into the tree without changing the tree,
+// it returns the path starting with "/" and the compiled constraints of its params
+func (t *treeNode) checkPut(path string, constraints map[string]ParamConstraint) (string, map[string]ParamConstraint, error) {
+	routerName := path
+	// the empty path is the route of group's prefix itself, it ends at the root
+	if path != "" && !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	if err := checkPattern(path); err != nil {
+		return path, nil, err
+	}
+	compiled, err := t.check(path, routerName, constraints)
+	return path, compiled, err
+}
+
+// conflictError is the error of a route conflicting with the routes in the tree
+type conflictError struct {
+	msg string
+}
+
+func (e *conflictError) Error() string {
+	return e.msg
+}
+
+func newConflictError(format string, a ...any) error {
+	return &conflictError{msg: fmt.Sprintf(format, a...)}
+}
+
+// nextSegment split the path into the dynamic segment at its start (:id, * or **)
+// or the static part ending before the next dynamic segment
+func nextSegment(path string) (segment string, rest string, dynamic bool) {
+	// dynamic segment is always at the start of a segment (after "/")
+	if path[0] == ':' || path[0] == '*' {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		return path[:end], path[end:], true
+	}
+	end := len(path)
+	for i := 0; i < len(path)-1; i++ {
+		if path[i] == '/' && (path[i+1] == ':' || path[i+1] == '*') {
+			end = i + 1
+			break
+		}
+	}
+	return path[:end], path[end:], false
+}
+
+// check walk the route on the tree without changing it, it returns the conflict with the existing routes
+// or the error of constraints, and the constraints of params compiled by their segments
+func (t *treeNode) check(path string, routerName string, constraints map[string]ParamConstraint) (map[string]ParamConstraint, error) {
+	var compiled map[string]ParamConstraint
+	// node is nil when the rest of route goes out of the existing nodes, it can not conflict any more
+	node := t
+	for path != "" {
+		segment, rest, dynamic := nextSegment(path)
+		path = rest
+		if !dynamic {
+			if node != nil {
+				node = node.findStatic(segment)
+			}
 			continue
 		}
-		children := t.children
-		isMatch := false
-		for _, node := range children {
-			// if match the url get to the next node
-			if node.name == name {
-				isMatch = true
-				t = node
-				break
+		if _, constraint := splitParam(segment); segment[0] == ':' && constraint != "" {
+			c, err := compileConstraint(constraint, constraints)
+			if err != nil {
+				return nil, fmt.Errorf("%v in route '%s'", err, routerName)
 			}
+			if compiled == nil {
+				compiled = make(map[string]ParamConstraint)
+			}
+			compiled[segment] = c
 		}
-		// if not match generate the node to save the name of url
-		if !isMatch {
-			isEnd := false
-			if index == len(strs)-1 {
-				isEnd = true
+		if node != nil {
+			child, err := node.findDynamic(segment, routerName)
+			if err != nil {
+				return nil, err
 			}
-			node := &treeNode{name: name, children: make([]*treeNode, 0), isEnd: isEnd}
-			children = append(children, node)
-			t.children = children
-			t = node
+			node = child
 		}
 	}
-	// back to root
-	t = root
+	if node != nil && node.isEnd && node.routerName != routerName {
+		return nil, newConflictError("route '%s' duplicates the existing route '%s'", routerName, node.routerName)
+	}
+	return compiled, nil
+}
+
+// checkPattern check the syntax of every segment before the route is put into the tree
+func checkPattern(path string) error {
+	segments := strings.Split(path, "/")
+	names := make(map[string]bool)
+	for i, segment := range segments {
+		switch {
+		case segment == "**":
+			if i != len(segments)-1 {
+				return fmt.Errorf("'**' must be the last segment of route '%s'", path)
+			}
+		case segment == "*":
+		case strings.HasPrefix(segment, "*"):
+			return fmt.Errorf("invalid segment '%s' in route '%s', use '*' or '**'", segment, path)
+		case strings.HasPrefix(segment, ":"):
+			name, constraint := splitParam(segment)
+			if name == "" || strings.ContainsAny(name, ":*<>") {
+				return fmt.Errorf("invalid param name '%s' in route '%s'", segment, path)
+			}
+			if constraint == "" && strings.HasSuffix(segment, "<>") {
+				return fmt.Errorf("invalid param constraint '%s' in route '%s'", segment, path)
+			}
+			if names[name] {
+				return fmt.Errorf("duplicate param name '%s' in route '%s'", segment, path)
+			}
+			names[name] = true
+		}
+	}
+	return nil
+}
+
+// putStatic insert the static part into the tree, split the node if only a part of its name is matched
+func (t *treeNode) putStatic(path string) *treeNode {
+	for {
+		if t.children == nil {
+			t.children = make(map[byte]*treeNode)
+		}
+		child, ok := t.children[path[0]]
+		if !ok {
+			child = &treeNode{name: path, kind: staticKind}
+			t.children[path[0]] = child
+			return child
+		}
+		l := longestCommonPrefix(child.name, path)
+		if l < len(child.name) {
+			// split the child: /user/ + /users/ ---> /user + (/, s/)
+			split := *child
+			split.name = child.name[l:]
+			*child = treeNode{
+				name:     child.name[:l],
+				kind:     staticKind,
+				children: map[byte]*treeNode{split.name[0]: &split},
+			}
+		}
+		if l == len(path) {
+			return child
+		}
+		t = child
+		path = path[l:]
+	}
+}
+
+// findStatic find the node of the static part without changing the tree, it returns nil if the node does not exist
+func (t *treeNode) findStatic(path string) *treeNode {
+	for {
+		child, ok := t.children[path[0]]
+		if !ok || longestCommonPrefix(child.name, path) < len(child.name) {
+			return nil
+		}
+		if len(child.name) == len(path) {
+			return child
+		}
+		t = child
+		path = path[len(child.name):]
+	}
+}
+
+// findDynamic find the child of the dynamic segment (:id, * or **), it returns nil if the child does not exist.
+// "*" and :id both match one segment, so they can not be the children of the same node
+// the params with different constraints can be the children of the same node, they are tried one by one
+func (t *treeNode) findDynamic(segment string, routerName string) (*treeNode, error) {
+	switch segment {
+	case "**":
+		return t.catchAllChild, nil
+	case "*":
+		if len(t.paramChildren) > 0 {
+			return nil, newConflictError("'%s' in route '%s' is ambiguous with '%s' in the existing route '%s'",
+				segment, routerName, t.paramChildren[0].name, t.paramChildren[0].owner)
+		}
+		return t.wildcardChild, nil
+	}
+	if t.wildcardChild != nil {
+		return nil, newConflictError("'%s' in route '%s' is ambiguous with '%s' in the existing route '%s'",
+			segment, routerName, t.wildcardChild.name, t.wildcardChild.owner)
+	}
+	_, constraint := splitParam(segment)
+	for _, child := range t.paramChildren {
+		if child.name == segment {
+			return child, nil
+		}
+		if _, c := splitParam(child.name); c == constraint {
+			return nil, newConflictError("'%s' in route '%s' conflicts with '%s' in the existing route '%s'",
+				segment, routerName, child.name, child.owner)
+		}
+	}
+	return nil, nil
+}
+
+// putDynamic insert the dynamic segment (:id, * or **) as the child of node with the compiled constraint,
+// the segment has been checked by findDynamic
+func (t *treeNode) putDynamic(segment string, routerName string, constraint ParamConstraint) *treeNode {
+	if child, err := t.findDynamic(segment, routerName); err == nil && child != nil {
+		return child
+	}
+	switch segment {
+	case "**":
+		t.catchAllChild = &treeNode{name: segment, kind: catchAllKind, owner: routerName}
+		return t.catchAllChild
+	case "*":
+		t.wildcardChild = &treeNode{name: segment, kind: wildcardKind, owner: routerName}
+		return t.wildcardChild
+	}
+	child := &treeNode{name: segment, kind: paramKind, owner: routerName, constraint: constraint}
+	if constraint == nil {
+		// the param without constraint matches any value, so it is the last one to try
+		t.paramChildren = append(t.paramChildren, child)
+		return child
+	}
+	index := len(t.paramChildren)
+	if index > 0 && t.paramChildren[index-1].constraint == nil {
+		index--
+	}
+	t.paramChildren = append(t.paramChildren, nil)
+	copy(t.paramChildren[index+1:], t.paramChildren[index:])
+	t.paramChildren[index] = child
+	return child
 }
 
 // get path: /user/get/11
-func (t *treeNode) Get(path string) *treeNode {
-	strs := strings.Split(path, "/")
-	routerName := ""
-	for index, name := range strs {
-		if index == 0 {
-			continue
+// the values matched by ":name", "*" and "**" segments are appended to params,
+// "*" is stored under the key "*" and the remainder matched by "**" under the key "**"
+// static segments have the highest priority, then :name, then "*" and "**" at last,
+// if a branch can not match the rest of path the next one will be tried
+// Get never writes the tree, it returns the route registered on the matched node
+// so it is safe to be called by concurrent requests
+func (t *treeNode) Get(path string, params *Params) (routerName string, ok bool) {
+	node := t.get(path, params)
+	if node == nil {
+		return "", false
+	}
+	return node.routerName, true
+}
+
+// get match the path on the children of t, the name of t has been matched
+func (t *treeNode) get(path string, params *Params) *treeNode {
+	if path == "" {
+		if t.isEnd {
+			return t
+		}
+		// /static/** match /static/ with an empty remainder
+		if t.catchAllChild != nil && t.catchAllChild.isEnd {
+			*params = append(*params, Param{Key: "**", Value: ""})
+			return t.catchAllChild
+		}
+		return nil
+	}
+	if child, ok := t.children[path[0]]; ok && strings.HasPrefix(path, child.name) {
+		if node := child.get(path[len(child.name):], params); node != nil {
+			return node
+		}
+	}
+	if len(t.paramChildren) > 0 || t.wildcardChild != nil {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
 		}
-		children := t.children
-		isMatch := false
-		for _, node := range children {
-			if node.name == name ||
-				node.name == "*" ||
-				strings.Contains(node.name, ":") {
-				isMatch = true
-				routerName += "/" + node.name
-				node.routerName = routerName
-				t = node
-				if index == len(strs)-1 {
+		if end > 0 {
+			value := path[:end]
+			for _, child := range t.paramChildren {
+				if child.constraint != nil && !child.constraint(value) {
+					continue
+				}
+				if node := child.getDynamic(value, path[end:], params); node != nil {
 					return node
 				}
-				break
 			}
-		}
-		if !isMatch {
-			for _, node := range children {
-				if node.name == "**" {
-					routerName += "/" + node.name
-					node.routerName = routerName
+			if t.wildcardChild != nil {
+				if node := t.wildcardChild.getDynamic(value, path[end:], params); node != nil {
 					return node
 				}
 			}
 		}
 	}
+	if t.catchAllChild != nil && t.catchAllChild.isEnd {
+		*params = append(*params, Param{Key: "**", Value: path})
+		return t.catchAllChild
+	}
+	return nil
+}
+
+// GetCaseInsensitive match the path ignoring the case of static parts,
+// it returns the path in the casing of the registered route, the values of dynamic segments are kept
+func (t *treeNode) GetCaseInsensitive(path string) (string, bool) {
+	fixed, ok := t.getCaseInsensitive(path, make([]byte, 0, len(path)))
+	return string(fixed), ok
+}
+
+// getCaseInsensitive match the path on the children of t like get, the matched part is appended to fixed
+func (t *treeNode) getCaseInsensitive(path string, fixed []byte) ([]byte, bool) {
+	if path == "" {
+		return fixed, t.isEnd || t.catchAllChild != nil && t.catchAllChild.isEnd
+	}
+	for _, c := range caseVariants(path[0]) {
+		child, ok := t.children[c]
+		if !ok || len(path) < len(child.name) || !strings.EqualFold(path[:len(child.name)], child.name) {
+			continue
+		}
+		if out, ok := child.getCaseInsensitive(path[len(child.name):], append(fixed, child.name...)); ok {
+			return out, true
+		}
+	}
+	if len(t.paramChildren) > 0 || t.wildcardChild != nil {
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
+		}
+		if end > 0 {
+			value := path[:end]
+			for _, child := range t.paramChildren {
+				if child.constraint != nil && !child.constraint(value) {
+					continue
+				}
+				if out, ok := child.getCaseInsensitive(path[end:], append(fixed, value...)); ok {
+					return out, true
+				}
+			}
+			if t.wildcardChild != nil {
+				if out, ok := t.wildcardChild.getCaseInsensitive(path[end:], append(fixed, value...)); ok {
+					return out, true
+				}
+			}
+		}
+	}
+	if t.catchAllChild != nil && t.catchAllChild.isEnd {
+		return append(fixed, path...), true
+	}
+	return nil, false
+}
+
+// caseVariants return the byte and its other case if it is a letter
+func caseVariants(c byte) []byte {
+	switch {
+	case 'a' <= c && c <= 'z':
+		return []byte{c, c - 'a' + 'A'}
+	case 'A' <= c && c <= 'Z':
+		return []byte{c, c - 'A' + 'a'}
+	}
+	return []byte{c}
+}
+
+// getDynamic store the value of the dynamic node t in params and match the rest of path on its children
+func (t *treeNode) getDynamic(value string, path string, params *Params) *treeNode {
+	*params = append(*params, Param{Key: paramKey(t.name), Value: value})
+	if node := t.get(path, params); node != nil {
+		return node
+	}
+	*params = (*params)[:len(*params)-1]
 	return nil
 }
+
+// paramKey get the key of a dynamic segment like :id<int> ---> id
+func paramKey(name string) string {
+	key, _ := splitParam(name)
+	return key
+}
+
+// splitParam split the param segment into the name and the constraint like :id<int> ---> (id, int)
+func splitParam(segment string) (name string, constraint string) {
+	name = strings.TrimPrefix(segment, ":")
+	if i := strings.IndexByte(name, '<'); i >= 0 && strings.HasSuffix(name, ">") {
+		return name[:i], name[i+1 : len(name)-1]
+	}
+	return name, ""
+}
+
+// longestCommonPrefix return the length of common prefix of a and b
+func longestCommonPrefix(a, b string) int {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return i
+}
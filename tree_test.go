@@ -2,24 +2,230 @@ package vex
 
 import (
 	"fmt"
+	"strconv"
 	"testing"
 )
 
 func TestTreeNode(t *testing.T) {
 	// this is a test of prefix tree to match the routes you add.
-	root := &treeNode{name: "/", children: make([]*treeNode, 0)}
-
-	root.Put("/user/get/:id")
-	root.Put("/user/create/hello")
-	root.Put("/user/create/aaa")
-	root.Put("/order/get/aaa")
-
-	node := root.Get("/user/get/1")
-	fmt.Println(node)
-	node = root.Get("/user/create/hello")
-	fmt.Println(node)
-	node = root.Get("/user/create/aaa")
-	fmt.Println(node)
-	node = root.Get("/order/get/aaa")
-	fmt.Println(node)
+	root := &treeNode{}
+
+	root.Put("/user/get/:id", nil)
+	root.Put("/user/create/hello", nil)
+	root.Put("/user/create/aaa", nil)
+	root.Put("/order/get/aaa", nil)
+
+	var params Params
+	fmt.Println(root.Get("/user/get/1", &params))
+	fmt.Println(root.Get("/user/create/hello", &params))
+	fmt.Println(root.Get("/user/create/aaa", &params))
+	fmt.Println(root.Get("/order/get/aaa", &params))
+}
+
+func TestTreeNodeParams(t *testing.T) {
+	root := &treeNode{}
+	root.Put("/user/:id/info", nil)
+	root.Put("/file/*/name", nil)
+	root.Put("/static/**", nil)
+
+	tests := []struct {
+		path   string
+		params Params
+	}{
+		{"/user/1/info", Params{{Key: "id", Value: "1"}}},
+		{"/file/a.txt/name", Params{{Key: "*", Value: "a.txt"}}},
+		{"/static/js/vex/app.js", Params{{Key: "**", Value: "js/vex/app.js"}}},
+	}
+	for _, test := range tests {
+		var params Params
+		if _, ok := root.Get(test.path, &params); !ok {
+			t.Fatalf("%s: route not found", test.path)
+		}
+		if fmt.Sprint(params) != fmt.Sprint(test.params) {
+			t.Errorf("%s: params = %v, want %v", test.path, params, test.params)
+		}
+	}
+}
+
+func TestTreeNodePriority(t *testing.T) {
+	root := &treeNode{}
+	// register the dynamic routes first, static routes must still win
+	root.Put("/user/**", nil)
+	root.Put("/user/:id", nil)
+	root.Put("/user/:id/info", nil)
+	root.Put("/user/me", nil)
+	root.Put("/user/me/settings", nil)
+	root.Put("/users", nil)
+
+	tests := []struct {
+		path       string
+		routerName string
+		params     Params
+	}{
+		{"/user/me", "/user/me", nil},
+		{"/user/1", "/user/:id", Params{{Key: "id", Value: "1"}}},
+		{"/users", "/users", nil},
+		{"/user/me/settings", "/user/me/settings", nil},
+		// /user/me/ dead ends, backtrack to :id
+		{"/user/me/info", "/user/:id/info", Params{{Key: "id", Value: "me"}}},
+		{"/user/1/other", "/user/**", Params{{Key: "**", Value: "1/other"}}},
+	}
+	for _, test := range tests {
+		var params Params
+		routerName, ok := root.Get(test.path, &params)
+		if !ok {
+			t.Fatalf("%s: route not found", test.path)
+		}
+		if routerName != test.routerName {
+			t.Errorf("%s: matched %s, want %s", test.path, routerName, test.routerName)
+		}
+		if fmt.Sprint(params) != fmt.Sprint(test.params) {
+			t.Errorf("%s: params = %v, want %v", test.path, params, test.params)
+		}
+	}
+	var params Params
+	if routerName, ok := root.Get("/order", &params); ok {
+		t.Errorf("/order: matched %s, want not found", routerName)
+	}
+}
+
+func TestTreeNodeSplit(t *testing.T) {
+	root := &treeNode{}
+	root.Put("/search", nil)
+	root.Put("/support", nil)
+	root.Put("/sea", nil)
+	root.Put("/s", nil)
+	for _, path := range []string{"/search", "/support", "/sea", "/s"} {
+		var params Params
+		if routerName, ok := root.Get(path, &params); !ok || routerName != path {
+			t.Errorf("%s: matched %s", path, routerName)
+		}
+	}
+	var params Params
+	if routerName, ok := root.Get("/se", &params); ok {
+		t.Errorf("/se: matched %s, want not found", routerName)
+	}
+}
+
+func BenchmarkTreeNodeGet(b *testing.B) {
+	root := &treeNode{}
+	for i := 0; i < 500; i++ {
+		root.Put("/api/resource"+strconv.Itoa(i)+"/:id", nil)
+	}
+	params := make(Params, 0, 4)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		params = params[:0]
+		root.Get("/api/resource499/11", &params)
+	}
+}
+
+func TestTreeNodeConflict(t *testing.T) {
+	tests := []struct {
+		routes []string
+		err    string
+	}{
+		{[]string{"/a/:id", "/a/:name"}, "':name' in route '/a/:name' conflicts with ':id' in the existing route '/a/:id'"},
+		{[]string{"/a/:id/x", "/a/*/y"}, "'*' in route '/a/*/y' is ambiguous with ':id' in the existing route '/a/:id/x'"},
+		{[]string{"/a/*", "/a/:id"}, "':id' in route '/a/:id' is ambiguous with '*' in the existing route '/a/*'"},
+		{[]string{"/a/**/b"}, "'**' must be the last segment of route '/a/**/b'"},
+		{[]string{"/a/:id/b/:id"}, "duplicate param name ':id' in route '/a/:id/b/:id'"},
+		{[]string{"/a/***"}, "invalid segment '***' in route '/a/***', use '*' or '**'"},
+		{[]string{"/a/:"}, "invalid param name ':' in route '/a/:'"},
+		{[]string{"/a", "a"}, "route 'a' duplicates the existing route '/a'"},
+	}
+	for _, test := range tests {
+		root := &treeNode{}
+		var err error
+		for _, route := range test.routes {
+			if err = root.Put(route, nil); err != nil {
+				break
+			}
+		}
+		if err == nil || err.Error() != test.err {
+			t.Errorf("%v: err = %v, want %s", test.routes, err, test.err)
+		}
+	}
+	// the catch-all and static routes can live with a param
+	root := &treeNode{}
+	for _, route := range []string{"/a/:id", "/a/**", "/a/b", "/a/:id/c"} {
+		if err := root.Put(route, nil); err != nil {
+			t.Errorf("%s: %v", route, err)
+		}
+	}
+}
+
+func TestTreeNodeFailedPut(t *testing.T) {
+	root := &treeNode{}
+	// the failed routes do not leave their nodes in the tree
+	if err := root.Put("/b/*/c/:x<(>", nil); err == nil {
+		t.Fatal("the invalid regular expression should fail")
+	}
+	root.Put("/d/:id", nil)
+	if err := root.Put("/d/:id/*/e/:x<(>", nil); err == nil {
+		t.Fatal("the invalid regular expression should fail")
+	}
+	for _, route := range []string{"/b/:y", "/d/:id/:z"} {
+		if err := root.Put(route, nil); err != nil {
+			t.Errorf("%s: %v", route, err)
+		}
+	}
+	var params Params
+	if _, ok := root.Get("/d/1/2", &params); !ok {
+		t.Error("/d/1/2 is not matched")
+	}
+}
+
+func TestTreeNodeConstraint(t *testing.T) {
+	root := &treeNode{}
+	even := func(v string) bool { return isInt(v) && (v[len(v)-1]-'0')%2 == 0 }
+	for _, route := range []string{
+		"/orders/:id<int>",
+		"/orders/:slug<[a-z0-9_-]+>",
+		"/orders/:name",
+		"/u/:uid<uuid>/info",
+		"/num/:n<even>",
+	} {
+		if err := root.Put(route, map[string]ParamConstraint{"even": even}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		path       string
+		routerName string
+		params     Params
+	}{
+		{"/orders/12", "/orders/:id<int>", Params{{Key: "id", Value: "12"}}},
+		{"/orders/new_order", "/orders/:slug<[a-z0-9_-]+>", Params{{Key: "slug", Value: "new_order"}}},
+		{"/orders/New", "/orders/:name", Params{{Key: "name", Value: "New"}}},
+		{"/u/0b5c2b59-1a2c-4c7e-9f43-a1b2c3d4e5f6/info", "/u/:uid<uuid>/info", Params{{Key: "uid", Value: "0b5c2b59-1a2c-4c7e-9f43-a1b2c3d4e5f6"}}},
+		{"/num/4", "/num/:n<even>", Params{{Key: "n", Value: "4"}}},
+	}
+	for _, test := range tests {
+		var params Params
+		routerName, ok := root.Get(test.path, &params)
+		if !ok || routerName != test.routerName {
+			t.Errorf("%s: matched %s, want %s", test.path, routerName, test.routerName)
+		}
+		if fmt.Sprint(params) != fmt.Sprint(test.params) {
+			t.Errorf("%s: params = %v, want %v", test.path, params, test.params)
+		}
+	}
+	for _, path := range []string{"/u/abc/info", "/num/3"} {
+		var params Params
+		if routerName, ok := root.Get(path, &params); ok {
+			t.Errorf("%s: matched %s, want not found", path, routerName)
+		}
+	}
+
+	if err := root.Put("/orders/:no<int>", nil); err == nil {
+		t.Error("expected conflict of the same constraint")
+	}
+	if err := root.Put("/bad/:id<[a-z>", nil); err == nil {
+		t.Error("expected error of invalid regular expression")
+	}
+	if err := root.Put("/bad/:id<>", nil); err == nil {
+		t.Error("expected error of empty constraint")
+	}
 }
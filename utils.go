@@ -1,6 +1,7 @@
 package vex
 
 import (
+	"path"
 	"strings"
 	"unicode"
 	"unsafe"
@@ -14,6 +15,42 @@ func SubStringLast(str string, substr string) string {
 	return str[index+len(substr):]
 }
 
+// joinPaths join the relative path to the absolute path, the trailing "/" of relative path is kept
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+	finalPath := path.Join(absolutePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(finalPath, "/") {
+		return finalPath + "/"
+	}
+	return finalPath
+}
+
+// cleanPath is the URL version of path.Clean, it returns a canonical URL path for p, eliminating "//", "." and "..",
+// the trailing slash of p is kept
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	cleaned := path.Clean(p)
+	if p[len(p)-1] == '/' && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// toggleTrailingSlash add the trailing slash to p or remove it
+func toggleTrailingSlash(p string) string {
+	if strings.HasSuffix(p, "/") {
+		return p[:len(p)-1]
+	}
+	return p + "/"
+}
+
 // judge the character is or not in ASCII
 func isASCII(s string) bool {
 	for i := 0; i < len(s); i++ {
@@ -9,10 +9,18 @@ import (
 	"fmt"
 	vexLog "github.com/axzed/vex/log"
 	"github.com/axzed/vex/render"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 	"html/template"
-	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 // ANY is the other name of "ANY" means url use ANY method
@@ -26,128 +34,321 @@ type HandleFunc func(ctx *Context)
 // input the handleFunc before process then return the handle Func which after process
 type MiddlewareFunc func(handleFunc HandleFunc) HandleFunc
 
+// AdaptMiddleware turn the MiddlewareFunc into a HandleFunc of the handler chain,
+// the next of middleware executes the rest of chain by ctx.Next(),
+// if the middleware does not call next the rest of chain is aborted
+func AdaptMiddleware(middlewareFunc MiddlewareFunc) HandleFunc {
+	handleFunc := middlewareFunc(func(ctx *Context) {
+		ctx.Next()
+	})
+	return func(ctx *Context) {
+		index := ctx.index
+		handleFunc(ctx)
+		if ctx.index == index {
+			ctx.Abort()
+		}
+	}
+}
+
+// adaptMiddlewares turn the MiddlewareFunc list into HandleFunc list
+func adaptMiddlewares(middlewareFuncs []MiddlewareFunc) []HandleFunc {
+	handleFuncs := make([]HandleFunc, 0, len(middlewareFuncs))
+	for _, middlewareFunc := range middlewareFuncs {
+		handleFuncs = append(handleFuncs, AdaptMiddleware(middlewareFunc))
+	}
+	return handleFuncs
+}
+
 // Routing groups
 type routerGroup struct {
-	name               string                                 // Router group's name
-	handleFuncMap      map[string]map[string]HandleFunc       // Each routing group's handler's function
-	middlewaresFuncMap map[string]map[string][]MiddlewareFunc // Each routing group's middlewaresFunction's function
-	handlerMethodMap   map[string][]string                    // Support different request methods && its urls (store different request method type)
-	treeNode           *treeNode                              // prefix router match tree
-	middlewares        []MiddlewareFunc                       // middlewares function list
+	name               string                             // Router group's name, the full prefix joined with its parents' name
+	handleFuncMap      map[string]map[string]HandleFunc   // Each routing group's handler's function
+	middlewaresFuncMap map[string]map[string][]HandleFunc // Each routing group's middlewaresFunction's function
+	handlersChainMap   map[string]map[string][]HandleFunc // Each route's handler chain compiled when the route or middleware is added
+	handlerMethodMap   map[string][]string                // Support different request methods && its urls (store different request method type)
+	treeNode           *treeNode                          // prefix router match tree
+	middlewares        []HandleFunc                       // middlewares function list
+	router             *router                            // the router this group belongs to
+	parent             *routerGroup                       // the parent group, nil means top level group
 }
 
 // Use function to add Middleware to the handleFunc
 // ... means you can add multi middleware to the func
+// the middlewares are executed in the order they are added
 func (r *routerGroup) Use(middlewareFunc ...MiddlewareFunc) {
-	r.middlewares = append(r.middlewares, middlewareFunc...)
+	r.UseHandleFunc(adaptMiddlewares(middlewareFunc)...)
 }
 
-// methodHandle is the function when you need to executive the middleware in request
-func (r *routerGroup) methodHandle(name string, method string, handleFunc HandleFunc, ctx *Context) {
-	// if you have set the Middleware
-	// exec Middleware
-	// common level middleware
-	if r.middlewares != nil {
-		for _, middlewareFunc := range r.middlewares {
-			handleFunc = middlewareFunc(handleFunc)
-		}
+// UseHandleFunc add the middlewares in the form of HandleFunc,
+// the middleware calls ctx.Next() to execute the rest of chain and ctx.Abort() to stop it
+//
+//	g.UseHandleFunc(func(ctx *vex.Context) {
+//	    start := time.Now()
+//	    ctx.Next()
+//	    log.Println(time.Since(start))
+//	})
+func (r *routerGroup) UseHandleFunc(middlewares ...HandleFunc) {
+	r.router.mu.Lock()
+	defer r.router.mu.Unlock()
+	r.middlewares = append(r.middlewares, middlewares...)
+	// the chains of the group and its children contain the middlewares
+	r.router.rebuildHandlers()
+}
+
+// methodHandle build the handler chain of the route
+// the chain is the middlewares of the engine, the parent groups, the group, the route and the handleFunc at last
+// the caller must hold the lock of router
+func (r *routerGroup) methodHandle(name string, method string, handleFunc HandleFunc) []HandleFunc {
+	return r.buildChain(r.middlewaresFuncMap[name][method], handleFunc)
+}
+
+// buildChain build the handler chain of the route with its middlewares, it panics if the chain is too long
+func (r *routerGroup) buildChain(routeMiddlewares []HandleFunc, handleFunc HandleFunc) []HandleFunc {
+	groups := make([]*routerGroup, 0, 2)
+	for group := r; group != nil; group = group.parent {
+		groups = append(groups, group)
 	}
-	// middlewareFuncs is router's middlewares function you set
-	middlewareFuncs := r.middlewaresFuncMap[name][method]
-	// exec the routerLevel middlewares
-	if middlewareFuncs != nil {
-		for _, middlewareFunc := range middlewareFuncs {
-			handleFunc = middlewareFunc(handleFunc)
-		}
+	// engine level middleware is read when the chain is built, so it does not matter Use is called before or after Group
+	handlers := append([]HandleFunc(nil), r.router.engine.middlewares...)
+	// common level middleware, the parents' middlewares are before the group's
+	for i := len(groups) - 1; i >= 0; i-- {
+		handlers = append(handlers, groups[i].middlewares...)
+	}
+	// the routerLevel middlewares
+	handlers = append(handlers, routeMiddlewares...)
+	handlers = append(handlers, handleFunc)
+	checkChainLength(handlers)
+	return handlers
+}
+
+// checkChainLength panic if the handler chain is too long to be aborted
+func checkChainLength(handlers []HandleFunc) {
+	if len(handlers) >= abortIndex {
+		panic(fmt.Sprintf("vex: too many handlers in the chain, the max is %d", abortIndex-1))
 	}
-	// exec handleFunc you set in api
-	handleFunc(ctx)
 }
 
 // handle use this function to set the HandleFunc and middlewares into the mapping url
-func (r *routerGroup) handle(name string, method string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) {
+// it is safe to add routes while the engine is serving requests
+// it returns the Route which can be named for the reverse url generation
+func (r *routerGroup) handle(name string, method string, handleFunc HandleFunc, middlewares []HandleFunc) *Route {
+	// the empty name matches the group's prefix itself like Route.Path, "/api" for the group "/api" and "/" for the group "/"
+	if name == "" && strings.HasSuffix(r.name, "/") {
+		name = "/"
+	}
+	r.router.mu.Lock()
+	defer r.router.mu.Unlock()
+	_, ok := r.handleFuncMap[name][method]
+	if ok {
+		panic(fmt.Sprintf("vex: route '%s %s' in group '%s' is already registered", method, name, r.name))
+	}
+	// ANY route handles all the methods, the other method of the same route will never be matched
+	if _, ok = r.handleFuncMap[name][ANY]; ok || (method == ANY && len(r.handleFuncMap[name]) > 0) {
+		panic(fmt.Sprintf("vex: route '%s %s' in group '%s' is ambiguous with the ANY route of the same url", method, name, r.name))
+	}
+	// check the route before any state is changed, so a failed route does not leave a handler in group
+	chain := r.buildChain(middlewares, handleFunc)
+	if _, _, err := r.treeNode.checkPut(name, r.router.engine.constraints); err != nil {
+		panic(routeError(r.name, err))
+	}
+	fullPath := joinPaths(r.name, name)
+	r.router.checkRoute(r.name, method, fullPath)
+	r.treeNode.Put(name, r.router.engine.constraints)
+	r.router.addRoute(method, fullPath)
 	// use group's name to init handleFunc and middlewares list
-	_, ok := r.handleFuncMap[name]
+	_, ok = r.handleFuncMap[name]
 	// init the function of this group of routes
 	if !ok {
 		r.handleFuncMap[name] = make(map[string]HandleFunc)
-		r.middlewaresFuncMap[name] = make(map[string][]MiddlewareFunc)
-	}
-	_, ok = r.handleFuncMap[name][method]
-	if ok {
-		panic("With duplicate routes")
+		r.middlewaresFuncMap[name] = make(map[string][]HandleFunc)
 	}
 	// add the handleFunc for the mapping route
 	r.handleFuncMap[name][method] = handleFunc
 	// add the middlewaresFunc for the mapping route
-	r.middlewaresFuncMap[name][method] = append(r.middlewaresFuncMap[name][method], middlewareFunc...)
-	// set the prefix tree's root node
-	r.treeNode.Put(name)
+	r.middlewaresFuncMap[name][method] = append(r.middlewaresFuncMap[name][method], middlewares...)
+	if r.handlersChainMap[name] == nil {
+		r.handlersChainMap[name] = make(map[string][]HandleFunc)
+	}
+	r.handlersChainMap[name][method] = chain
+	return &Route{Method: method, Path: fullPath, Host: r.router.host, engine: r.router.engine}
+}
+
+// routeError return the panic message of the route failed to put into the tree of group
+func routeError(group string, err error) string {
+	var conflict *conflictError
+	if errors.As(err, &conflict) {
+		return fmt.Sprintf("vex: route conflict in group '%s': %v", group, err)
+	}
+	return fmt.Sprintf("vex: invalid route in group '%s': %v", group, err)
+}
+
+// rebuildHandlers compile the handler chains of all the routes in group
+// the caller must hold the lock of router
+func (r *routerGroup) rebuildHandlers() {
+	for name, methods := range r.handleFuncMap {
+		for method, handleFunc := range methods {
+			r.handlersChainMap[name][method] = r.methodHandle(name, method, handleFunc)
+		}
+	}
+}
+
+// Handle registers the route with the handler chain, the last HandleFunc is the handler
+// and the ones before it are the route's middlewares
+//
+//	g.Handle(http.MethodGet, "/user/:id", auth, getUser)
+func (r *routerGroup) Handle(method string, name string, handlers ...HandleFunc) *Route {
+	if len(handlers) == 0 {
+		panic(fmt.Sprintf("vex: route '%s %s' in group '%s' has no handler", method, name, r.name))
+	}
+	return r.handle(name, method, handlers[len(handlers)-1], handlers[:len(handlers)-1])
 }
 
 // Any Get Post Put Delete is restful api
 // Any is a method support any type of request to our router
-func (r *routerGroup) ANY(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, ANY, handleFunc, middlewareFunc...)
+func (r *routerGroup) ANY(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, ANY, handleFunc, adaptMiddlewares(middlewareFunc))
 }
 
 // Get restful api
-func (r *routerGroup) GET(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodGet, handleFunc, middlewareFunc...)
+func (r *routerGroup) GET(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodGet, handleFunc, adaptMiddlewares(middlewareFunc))
 }
 
 // Post restful api
-func (r *routerGroup) POST(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodPost, handleFunc, middlewareFunc...)
+func (r *routerGroup) POST(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodPost, handleFunc, adaptMiddlewares(middlewareFunc))
 }
 
 // Delete restful api
-func (r *routerGroup) DELETE(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodDelete, handleFunc, middlewareFunc...)
+func (r *routerGroup) DELETE(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodDelete, handleFunc, adaptMiddlewares(middlewareFunc))
 }
 
 // Put restful api
-func (r *routerGroup) PUT(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodPut, handleFunc, middlewareFunc...)
+func (r *routerGroup) PUT(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodPut, handleFunc, adaptMiddlewares(middlewareFunc))
 }
 
 // Patch restful api
-func (r *routerGroup) PATCH(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodPatch, handleFunc, middlewareFunc...)
+func (r *routerGroup) PATCH(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodPatch, handleFunc, adaptMiddlewares(middlewareFunc))
 }
 
 // Options restful api
-func (r *routerGroup) OPTION(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodOptions, handleFunc, middlewareFunc...)
+func (r *routerGroup) OPTION(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodOptions, handleFunc, adaptMiddlewares(middlewareFunc))
 }
 
 // Head restful api
-func (r *routerGroup) HEAD(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) {
-	r.handle(name, http.MethodHead, handleFunc, middlewareFunc...)
+func (r *routerGroup) HEAD(name string, handleFunc HandleFunc, middlewareFunc ...MiddlewareFunc) *Route {
+	return r.handle(name, http.MethodHead, handleFunc, adaptMiddlewares(middlewareFunc))
 }
 
 // router defines a routerGroup's slice info
+// mu protects the routerGroups and their routes, requests hold the read lock while they are looking up the routes
+// the routers bound to hosts share the mu of engine's router
 type router struct {
 	routerGroups []*routerGroup // router's group
 	engine       *Engine
+	mu           *sync.RWMutex
+	host         string   // the host pattern set by Engine.Host, empty for the engine's router
+	hostLabels   []string // the labels of host pattern
+	// tree and routes keep the full paths of routes in all the groups, the groups with the same or overlapping prefixes
+	// like "/" and "/api" have their own trees, the route conflicting with another group's is found by them
+	tree   *treeNode
+	routes map[string]map[string]bool
+}
+
+// checkRoute check the route of group with its full path against the routes of all the groups in router,
+// it panics if the route conflicts. the caller must hold the lock of router
+func (r *router) checkRoute(group string, method string, fullPath string) {
+	methods := r.routes[fullPath]
+	if methods[method] {
+		panic(fmt.Sprintf("vex: route '%s %s' in group '%s' is already registered by another group", method, fullPath, group))
+	}
+	if methods[ANY] || (method == ANY && len(methods) > 0) {
+		panic(fmt.Sprintf("vex: route '%s %s' in group '%s' is ambiguous with the route of the same url in another group", method, fullPath, group))
+	}
+	if r.tree == nil {
+		r.tree = &treeNode{}
+		r.routes = make(map[string]map[string]bool)
+	}
+	if _, _, err := r.tree.checkPut(fullPath, r.engine.constraints); err != nil {
+		panic(routeError(group, err))
+	}
+}
+
+// addRoute keep the full path of route checked by checkRoute. the caller must hold the lock of router
+func (r *router) addRoute(method string, fullPath string) {
+	r.tree.Put(fullPath, r.engine.constraints)
+	if r.routes[fullPath] == nil {
+		r.routes[fullPath] = make(map[string]bool)
+	}
+	r.routes[fullPath][method] = true
 }
 
 // Group grouping the routes
 // initialize the routerGroups by using the Group function
 // take the routerGroup to manipulate the url
 func (r *router) Group(name string) *routerGroup {
+	return r.group(joinPaths("/", name), nil)
+}
+
+// Group create a child group, its prefix is joined with the group's name
+// and it inherits the middlewares of the group
+//
+//	api := engine.Group("/api/v1")
+//	admin := api.Group("/admin") // match /api/v1/admin/...
+func (r *routerGroup) Group(name string) *routerGroup {
+	return r.router.group(joinPaths(r.name, name), r)
+}
+
+// group create the routerGroup and keep the groups in the order of prefix length,
+// so the more specific group is matched first
+func (r *router) group(name string, parent *routerGroup) *routerGroup {
 	routerGroup := &routerGroup{
 		name:               name,
 		handleFuncMap:      make(map[string]map[string]HandleFunc),
-		middlewaresFuncMap: make(map[string]map[string][]MiddlewareFunc),
+		middlewaresFuncMap: make(map[string]map[string][]HandleFunc),
+		handlersChainMap:   make(map[string]map[string][]HandleFunc),
 		handlerMethodMap:   make(map[string][]string),
-		treeNode:           &treeNode{name: "/", children: make([]*treeNode, 0)},
+		treeNode:           &treeNode{},
+		router:             r,
+		parent:             parent,
 	}
-	routerGroup.Use(r.engine.middlewares...)
-	r.routerGroups = append(r.routerGroups, routerGroup)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	index := sort.Search(len(r.routerGroups), func(i int) bool {
+		return len(r.routerGroups[i].name) < len(name)
+	})
+	r.routerGroups = append(r.routerGroups, nil)
+	copy(r.routerGroups[index+1:], r.routerGroups[index:])
+	r.routerGroups[index] = routerGroup
 	return routerGroup
 }
 
+// rebuildHandlers compile the handler chains of all the groups after the middlewares are changed
+// the caller must hold the lock of router
+func (r *router) rebuildHandlers() {
+	for _, group := range r.routerGroups {
+		group.rebuildHandlers()
+	}
+}
+
+// matchPath check the group's prefix is at the start of path and return the rest of path
+// the prefix must end at a "/", so /v1 does not match /v1x
+func (r *routerGroup) matchPath(path string) (string, bool) {
+	prefix := strings.TrimSuffix(r.name, "/")
+	if !strings.HasPrefix(path, prefix) {
+		return "", false
+	}
+	rest := path[len(prefix):]
+	if rest != "" && rest[0] != '/' {
+		return "", false
+	}
+	return rest, true
+}
+
 // ErrorHandler is a type to handle error
 // int -> code , any -> msg
 type ErrorHandler func(err error) (int, any)
@@ -160,8 +361,70 @@ type Engine struct {
 	HTMLRender   render.HTMLRender
 	pool         sync.Pool
 	Logger       *vexLog.Logger
-	middlewares  []MiddlewareFunc
+	middlewares  []HandleFunc
 	errorHandler ErrorHandler
+	constraints  map[string]ParamConstraint // custom param constraints like :id<even>
+	namedRoutes  map[string]*Route          // the routes named by Route.Name
+	hosts        []*HostRouter              // the routers bound to host patterns, the ones with more literal labels first
+	noRoute      []HandleFunc               // handlers of the request not matched by any route
+	noMethod     []HandleFunc               // handlers of the request matched by url but not by method
+	allNoRoute   []HandleFunc               // noRoute handlers with the engine level middlewares
+	allNoMethod  []HandleFunc               // noMethod handlers with the engine level middlewares
+
+	// HandleMethodNotAllowed if enabled, the router answers 'Method Not Allowed' with the Allow header
+	// when the url is matched but the method is not, otherwise it answers 'Not Found'. default is true
+	HandleMethodNotAllowed bool
+	// HandleOPTIONS if enabled, the router answers the OPTIONS request automatically with the Allow header
+	// when the url has no OPTIONS handler. default is true
+	HandleOPTIONS bool
+	// RedirectTrailingSlash if enabled, the router redirects the request to the url with or without the trailing slash
+	// when only that one has a route, like /users/ ---> /users. default is true
+	RedirectTrailingSlash bool
+	// RedirectCleanPath if enabled, the router cleans the url with "//", "." or "..",
+	// and redirects the request to the cleaned url if it has a route, like /a/../users ---> /users. default is true
+	RedirectCleanPath bool
+	// RedirectCaseInsensitive if enabled, the router matches the url ignoring the case,
+	// and redirects the request to the url with the casing of the registered route, like /USERS ---> /users. default is false
+	RedirectCaseInsensitive bool
+
+	// ReadTimeout, ReadHeaderTimeout, WriteTimeout and IdleTimeout are set to the http.Server created by
+	// Run, RunTLS, RunListener and RunUnix, zero means no timeout like http.Server
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// ShutdownTimeout is the time waiting for the in-flight requests when the engine shuts down on the ShutdownSignals.
+	// default is 30s
+	ShutdownTimeout time.Duration
+	// ShutdownSignals are the signals shutting down the engine gracefully while it is running,
+	// set it to nil to handle the signals by yourself. default is SIGINT and SIGTERM
+	ShutdownSignals []os.Signal
+	// RestartSignals are the signals restarting the process without closing the listeners, see Engine.Restart.
+	// the restart is only supported on unix, and the main function must run the engine in the same way after it.
+	// default is nil, set it to enable the restart on signal:
+	//
+	//	engine.RestartSignals = []os.Signal{syscall.SIGHUP}
+	RestartSignals []os.Signal
+	// UseH2C if enabled, the servers created by engine serve HTTP/2 over cleartext (h2c) besides HTTP/1,
+	// it is used behind the proxy speaking HTTP/2 without TLS like the sidecar of service mesh. default is false
+	UseH2C bool
+	// HealthCheckTimeout is the timeout of each HealthChecker run by HealthzHandler and ReadyzHandler. default is 3s
+	HealthCheckTimeout time.Duration
+
+	serverMu     sync.Mutex
+	servers      map[*http.Server]*runningServer // the running servers and their listeners
+	shuttingDown bool                            // Shutdown is called, no server can be started
+	handedOver   bool                            // the listeners are inherited by the new process of Restart
+	shutdownDone chan struct{}                   // closed when all the servers have shut down
+	signalOnce   sync.Once
+
+	startHooks    []HookFunc    // the hooks of OnStart
+	readyHooks    []HookFunc    // the hooks of OnReady
+	shutdownHooks []HookFunc    // the hooks of OnShutdown
+	startOnce     sync.Once     // run the OnStart and OnReady hooks once
+	startErr      error         // the error of OnStart hooks
+	ready         atomic.Bool   // the engine is serving and not shutting down
+	healthChecks  []healthCheck // the registered health checkers
 }
 
 // New returns a new blank Engine instance without any middleware attached.
@@ -169,13 +432,29 @@ func New() *Engine {
 	// initialize the instance of vex
 	// it contains:
 	// router: the mapping method of url and its handleFunc
-	// funcMap: template function mapping
+	// funcMap: template function mapping, it has the "url" function of named routes
 	// HTMLRender: render of HTML files
+	// Logger: the logger used by context like Recovery
 	engine := &Engine{
-		router:     &router{},
-		funcMap:    nil,
-		HTMLRender: render.HTMLRender{},
+		router:      &router{mu: new(sync.RWMutex)},
+		HTMLRender:  render.HTMLRender{},
+		Logger:      vexLog.Default(),
+		namedRoutes: make(map[string]*Route),
+
+		HandleMethodNotAllowed: true,
+		HandleOPTIONS:          true,
+		RedirectTrailingSlash:  true,
+		RedirectCleanPath:      true,
+
+		ShutdownTimeout:    30 * time.Second,
+		HealthCheckTimeout: 3 * time.Second,
+		ShutdownSignals:    []os.Signal{os.Interrupt, syscall.SIGTERM},
+		servers:            make(map[*http.Server]*runningServer),
+		shutdownDone:       make(chan struct{}),
 	}
+	engine.router.engine = engine
+	engine.funcMap = template.FuncMap{"url": engine.URL}
+	engine.rebuildHandlers()
 	engine.pool.New = func() any {
 		return engine.allocateContext() // set context into pool to improve efficient
 	}
@@ -186,9 +465,7 @@ func New() *Engine {
 // Default method combine the use of logger && recover
 func Default() *Engine {
 	engine := New()
-	engine.Logger = vexLog.Default()
 	engine.Use(Logger, Recovery)
-	engine.router.engine = engine
 	return engine
 }
 
@@ -203,8 +480,12 @@ func (e *Engine) allocateContext() any {
 // SetHTMLTemplate
 // LoadHTMLTemplate
 // These three function is to render the html template files in memory
+// the "url" function is kept unless funcMap has its own
 func (e *Engine) SetFuncMap(funcMap template.FuncMap) {
-	e.funcMap = funcMap
+	e.funcMap = template.FuncMap{"url": e.URL}
+	for name, fn := range funcMap {
+		e.funcMap[name] = fn
+	}
 }
 
 func (e *Engine) SetHTMLTemplate(t *template.Template) {
@@ -219,77 +500,330 @@ func (e *Engine) LoadHTMLTemplate(pattern string) {
 // implement the interface method ServeHTTP
 func (e *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := e.pool.Get().(*Context)
-	ctx.W = w
+	ctx.writermem.reset(w)
+	ctx.W = &ctx.writermem
 	ctx.R = r
 	ctx.Logger = e.Logger
+	ctx.reset()
 	e.httpRequestHandle(ctx, w, r)
 	e.pool.Put(ctx)
 }
 
 // httpRequestHandle is a function to handle the router's request
 func (e *Engine) httpRequestHandle(ctx *Context, w http.ResponseWriter, r *http.Request) {
-	method := r.Method
-	for _, group := range e.routerGroups {
-		routerName := SubStringLast(r.URL.Path, group.name)
-		// get/1
-		// node has all routerName match to change the dynamic url like :id ---> 1
-		node := group.treeNode.Get(routerName)
-		// match
-		// node.isEnd means this tree node is at the end of url
-		// ps: if node is end of url then you url has not in a same method, so return 405
-		// ps: if node is not the end means this node is not the end you need to return 404
-		if node != nil && node.isEnd {
-			handle, ok := group.handleFuncMap[node.routerName][ANY]
-			if ok {
-				group.methodHandle(node.routerName, ANY, handle, ctx)
-				return
-			}
-			handle, ok = group.handleFuncMap[node.routerName][method]
-			if ok {
-				group.methodHandle(node.routerName, method, handle, ctx)
-				return
-			}
-			// url matched but not in a correct method return 405
-			w.WriteHeader(http.StatusMethodNotAllowed)
-			fmt.Fprintf(w, "%s %s not allowed\n", r.RequestURI, method)
+	handlers, allow, fromGET := e.lookup(ctx, r.Method, r.Host, r.URL.Path)
+	if handlers != nil {
+		// HEAD request is served by the GET handler without the body
+		if fromGET {
+			ctx.W = &headResponseWriter{ResponseWriter: ctx.W}
+		}
+		ctx.handlers = handlers
+		ctx.Next()
+		return
+	}
+	if allow != nil {
+		if r.Method == http.MethodOptions && e.HandleOPTIONS {
+			// answer the OPTIONS request automatically if it has no handler
+			ctx.W.Header().Set("Allow", strings.Join(allow, ", "))
+			ctx.W.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if e.HandleMethodNotAllowed {
+			// url matched but not in a correct method, run the NoMethod handlers
+			ctx.W.Header().Set("Allow", strings.Join(allow, ", "))
+			ctx.handlers = e.errorHandlers(true)
+			ctx.Next()
 			return
 		}
 	}
-	// if url is not match return 404
-	w.WriteHeader(http.StatusNotFound)
-	fmt.Fprintf(w, "%s not found\n", r.RequestURI)
+	// if url is not match, redirect to the variant of url which has a route or run the NoRoute handlers
+	if target, ok := e.redirectPath(ctx, r.Method, r.Host, r.URL.Path); ok {
+		ctx.handlers = e.redirectHandlers(target, r)
+	} else {
+		ctx.handlers = e.errorHandlers(false)
+	}
+	ctx.Params = ctx.Params[:0]
+	ctx.Next()
 }
 
-// Run attaches the router to a http.Server and starts listening and serving HTTP requests.
-// It is a shortcut for http.ListenAndServe(addr, router)
-// Note: this method will block the calling goroutine indefinitely unless an error happens.
-func (e *Engine) Run(port ...string) error {
-	http.Handle("/", e)
-	// 若端口号为空，则默认为8080
-	if len(port) == 0 {
-		port = append(port, ":8080")
+// redirectPath find the variant of path matched by a route with the method,
+// the variants are tried in order: the cleaned path, the path with or without the trailing slash,
+// and the path with the casing of the registered route
+func (e *Engine) redirectPath(ctx *Context, method string, host string, path string) (string, bool) {
+	if method == http.MethodConnect || path == "/" {
+		return "", false
+	}
+	matched := func(p string) bool {
+		handlers, _, _ := e.lookup(ctx, method, host, p)
+		return handlers != nil
 	}
-	if len(port) > 1 {
-		return errors.New("too many parameters")
+	candidates := make([]string, 0, 4)
+	if e.RedirectCleanPath {
+		if cleaned := cleanPath(path); cleaned != path {
+			candidates = append(candidates, cleaned)
+			path = cleaned
+		}
 	}
-	err := http.ListenAndServe(port[0], nil)
-	if err != nil {
-		log.Fatal(err)
+	if e.RedirectTrailingSlash {
+		candidates = append(candidates, toggleTrailingSlash(path))
 	}
-	return nil
+	for _, candidate := range candidates {
+		if candidate != "" && matched(candidate) {
+			return candidate, true
+		}
+	}
+	if e.RedirectCaseInsensitive {
+		candidates = append(candidates[:0], path)
+		if e.RedirectTrailingSlash {
+			candidates = append(candidates, toggleTrailingSlash(path))
+		}
+		for _, candidate := range candidates {
+			if fixed, ok := e.lookupCaseInsensitive(host, candidate); ok && matched(fixed) {
+				return fixed, true
+			}
+		}
+	}
+	return "", false
+}
+
+// lookupCaseInsensitive match the path ignoring the case of group prefix and static parts of routes
+// in the routers of matched hosts and the engine's router, it returns the path in the casing of the registered route
+func (e *Engine) lookupCaseInsensitive(host string, path string) (string, bool) {
+	e.router.mu.RLock()
+	defer e.router.mu.RUnlock()
+	for _, r := range e.hosts {
+		if !r.matchHost(host, nil) {
+			continue
+		}
+		if fixed, ok := r.lookupCaseInsensitive(path); ok {
+			return fixed, true
+		}
+	}
+	return e.router.lookupCaseInsensitive(path)
+}
+
+// lookupCaseInsensitive match the path ignoring the case in the groups of router
+// the caller must hold the read lock of router
+func (r *router) lookupCaseInsensitive(path string) (string, bool) {
+	for _, group := range r.routerGroups {
+		prefix := strings.TrimSuffix(group.name, "/")
+		if len(path) < len(prefix) || !strings.EqualFold(path[:len(prefix)], prefix) {
+			continue
+		}
+		rest := path[len(prefix):]
+		if rest != "" && rest[0] != '/' {
+			continue
+		}
+		if fixed, ok := group.treeNode.GetCaseInsensitive(rest); ok {
+			return prefix + fixed, true
+		}
+	}
+	return "", false
+}
+
+// redirectHandlers build the handler chain redirecting to the target with the engine level middlewares
+// GET request is redirected with 301 and the others with 308 to keep the method and body
+func (e *Engine) redirectHandlers(target string, r *http.Request) []HandleFunc {
+	code := http.StatusMovedPermanently
+	if r.Method != http.MethodGet {
+		code = http.StatusPermanentRedirect
+	}
+	// the target is the decoded path, escape it so the escaped characters like "?" and " " stay in the path
+	target = (&url.URL{Path: target}).EscapedPath()
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	e.router.mu.RLock()
+	defer e.router.mu.RUnlock()
+	return e.combineHandlers(nil, func(ctx *Context) {
+		ctx.StatusCode = code
+		http.Redirect(ctx.W, ctx.R, target, code)
+	})
 }
 
-// RunTLS attaches the router to a http.Server and starts listening and serving HTTPS (secure) requests.
-func (e *Engine) RunTLS(addr, certFile, keyFile string) {
-	err := http.ListenAndServeTLS(addr, certFile, keyFile, e.Handler())
-	if err != nil {
-		log.Fatal(err)
+// errorHandlers return the NoMethod or NoRoute handler chain
+func (e *Engine) errorHandlers(noMethod bool) []HandleFunc {
+	e.router.mu.RLock()
+	defer e.router.mu.RUnlock()
+	if noMethod {
+		return e.allNoMethod
 	}
+	return e.allNoRoute
+}
+
+// serveNotFound run the NoRoute handlers in place of the rest of chain,
+// it is used by the handler finding nothing to serve like Static, the engine level middlewares have run
+func (e *Engine) serveNotFound(ctx *Context) {
+	e.router.mu.RLock()
+	handlers := e.noRoute
+	e.router.mu.RUnlock()
+	if len(handlers) == 0 {
+		handlers = []HandleFunc{default404Handler}
+	}
+	ctx.handlers = handlers
+	ctx.index = -1
+	ctx.Next()
+}
+
+// NoRoute sets the handlers of the request which is not matched by any route, it answers 404 by default
+// the handlers run after the engine level middlewares like Logger and Recovery,
+// so they can render the response by the helpers of Context
+//
+//	engine.NoRoute(func(ctx *vex.Context) {
+//	    ctx.JSON(http.StatusNotFound, map[string]any{"code": 404, "msg": "not found"})
+//	})
+func (e *Engine) NoRoute(handlers ...HandleFunc) {
+	e.router.mu.Lock()
+	defer e.router.mu.Unlock()
+	e.noRoute = handlers
+	e.rebuildHandlers()
+}
+
+// NoMethod sets the handlers of the request whose url is matched but method is not, it answers 405 by default
+// the Allow header is set before the handlers run, and they run after the engine level middlewares as NoRoute
+func (e *Engine) NoMethod(handlers ...HandleFunc) {
+	e.router.mu.Lock()
+	defer e.router.mu.Unlock()
+	e.noMethod = handlers
+	e.rebuildHandlers()
+}
+
+// rebuildHandlers compile the handler chains of all routes, NoRoute and NoMethod
+// the caller must hold the lock of router
+func (e *Engine) rebuildHandlers() {
+	e.router.rebuildHandlers()
+	for _, r := range e.hosts {
+		r.rebuildHandlers()
+	}
+	e.allNoRoute = e.combineHandlers(e.noRoute, default404Handler)
+	e.allNoMethod = e.combineHandlers(e.noMethod, default405Handler)
+}
+
+// combineHandlers put the engine level middlewares before handlers, defaultHandler is used if no handler
+func (e *Engine) combineHandlers(handlers []HandleFunc, defaultHandler HandleFunc) []HandleFunc {
+	if len(handlers) == 0 {
+		handlers = []HandleFunc{defaultHandler}
+	}
+	combined := make([]HandleFunc, 0, len(e.middlewares)+len(handlers))
+	combined = append(combined, e.middlewares...)
+	combined = append(combined, handlers...)
+	checkChainLength(combined)
+	return combined
+}
+
+// default404Handler answers the request not matched by any route
+func default404Handler(ctx *Context) {
+	ctx.String(http.StatusNotFound, "%s not found\n", ctx.R.RequestURI)
+}
+
+// default405Handler answers the request whose method is not allowed
+func default405Handler(ctx *Context) {
+	ctx.String(http.StatusMethodNotAllowed, "%s %s not allowed\n", ctx.R.RequestURI, ctx.R.Method)
+}
+
+// lookup find the handler chain of request and store the url params in ctx
+// it only reads the routes under the read lock, the handler chain is executed by the caller after the lock is released
+// so the handler can add routes without deadlock
+// the routers of matched hosts are tried before the engine's router, the ones with more literal labels first, the host params are stored before the url params
+// if the url is matched but the method is not, it returns the sorted methods allowed by the url in all groups
+// fromGET means the HEAD request is matched by a GET route
+func (e *Engine) lookup(ctx *Context, method string, host string, path string) (handlers []HandleFunc, allow []string, fromGET bool) {
+	e.router.mu.RLock()
+	defer e.router.mu.RUnlock()
+	ctx.Params = ctx.Params[:0]
+	for _, r := range e.hosts {
+		if !r.matchHost(host, &ctx.Params) {
+			continue
+		}
+		if handlers, allow, fromGET = r.lookup(ctx, method, path, allow); handlers != nil {
+			return handlers, nil, fromGET
+		}
+		ctx.Params = ctx.Params[:0]
+	}
+	if handlers, allow, fromGET = e.router.lookup(ctx, method, path, allow); handlers != nil {
+		return handlers, nil, fromGET
+	}
+	if allow != nil {
+		if e.HandleOPTIONS {
+			allow = appendMethod(allow, http.MethodOptions)
+		}
+		sort.Strings(allow)
+	}
+	return nil, allow, false
+}
+
+// lookup find the handler chain in the groups of router, the params before the url params are kept
+// the methods of the url matched but not by method are appended to allow
+// the caller must hold the read lock of router
+func (r *router) lookup(ctx *Context, method string, path string, allow []string) ([]HandleFunc, []string, bool) {
+	base := len(ctx.Params)
+	for _, group := range r.routerGroups {
+		routerName, ok := group.matchPath(path)
+		if !ok {
+			continue
+		}
+		// get/1
+		// the route key is the name you register like /get/:id
+		// the matched values are stored in ctx.Params
+		ctx.Params = ctx.Params[:base]
+		key, ok := group.treeNode.Get(routerName, &ctx.Params)
+		if !ok {
+			continue
+		}
+		// the handler chains are compiled when the routes are added, so they are returned directly
+		methods := group.handlersChainMap[key]
+		if handlers, ok := methods[ANY]; ok {
+			return handlers, allow, false
+		}
+		if handlers, ok := methods[method]; ok {
+			return handlers, allow, false
+		}
+		if handlers, ok := methods[http.MethodGet]; ok && method == http.MethodHead {
+			return handlers, allow, true
+		}
+		// url matched but not in a same method, try the other groups
+		for m := range methods {
+			allow = appendMethod(allow, m)
+			if m == http.MethodGet {
+				allow = appendMethod(allow, http.MethodHead)
+			}
+		}
+	}
+	return nil, allow, false
+}
+
+// appendMethod append the method if it is not in the list
+func appendMethod(methods []string, method string) []string {
+	for _, m := range methods {
+		if m == method {
+			return methods
+		}
+	}
+	return append(methods, method)
+}
+
+// headResponseWriter discard the body written by the GET handler for a HEAD request
+type headResponseWriter struct {
+	ResponseWriter
+}
+
+func (w *headResponseWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	return len(b), nil
 }
 
 // Use is a method to use the default setting about logger and recovery
 func (e *Engine) Use(middlewares ...MiddlewareFunc) {
+	e.UseHandleFunc(adaptMiddlewares(middlewares)...)
+}
+
+// UseHandleFunc add the engine level middlewares in the form of HandleFunc
+// they apply to all the routes whenever the groups and routes are added
+func (e *Engine) UseHandleFunc(middlewares ...HandleFunc) {
+	e.router.mu.Lock()
+	defer e.router.mu.Unlock()
 	e.middlewares = append(e.middlewares, middlewares...)
+	e.rebuildHandlers()
 }
 
 // RegisterErrorHandler to register the handler in engine
@@ -297,6 +831,12 @@ func (e *Engine) RegisterErrorHandler(handler ErrorHandler) {
 	e.errorHandler = handler
 }
 
+// Handler return the http.Handler of engine for the server you run, it serves h2c if UseH2C is enabled.
+// the servers run by engine like Run and RunServer serve h2c with the HTTP/2 server configured on them,
+// so Shutdown drains the h2c connections too
 func (e *Engine) Handler() http.Handler {
-	return e
+	if !e.UseH2C {
+		return e
+	}
+	return h2c.NewHandler(e, &http2.Server{})
 }
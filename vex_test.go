@@ -0,0 +1,808 @@
+package vex
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// performRequest send a request to the engine and return the recorder
+func performRequest(e http.Handler, method, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	w := httptest.NewRecorder()
+	e.ServeHTTP(w, req)
+	return w
+}
+
+func TestConcurrentParamRoutes(t *testing.T) {
+	engine := New()
+	g := engine.Group("/api")
+	g.GET("/user/:id", func(ctx *Context) {
+		ctx.String(http.StatusOK, "user %s", ctx.Param("id"))
+	})
+	g.GET("/order/:no", func(ctx *Context) {
+		ctx.String(http.StatusOK, "order %s", ctx.Param("no"))
+	})
+	g.GET("/file/**", func(ctx *Context) {
+		ctx.String(http.StatusOK, "file %s", ctx.Param("**"))
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 300)
+	for i := 0; i < 100; i++ {
+		wg.Add(3)
+		i := i
+		check := func(path, want string) {
+			defer wg.Done()
+			w := performRequest(engine, http.MethodGet, path)
+			if w.Code != http.StatusOK || w.Body.String() != want {
+				errs <- fmt.Sprintf("%s: got %d %q, want %q", path, w.Code, w.Body.String(), want)
+			}
+		}
+		go check("/api/user/"+strconv.Itoa(i), "user "+strconv.Itoa(i))
+		go check("/api/order/"+strconv.Itoa(i), "order "+strconv.Itoa(i))
+		go check("/api/file/a/"+strconv.Itoa(i), "file a/"+strconv.Itoa(i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestConcurrentRegisterAndServe(t *testing.T) {
+	engine := New()
+	g := engine.Group("/api")
+	g.GET("/ping", func(ctx *Context) {
+		ctx.String(http.StatusOK, "pong")
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan string, 200)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			g.GET("/item"+strconv.Itoa(i)+"/:id", func(ctx *Context) {
+				ctx.String(http.StatusOK, ctx.Param("id"))
+			})
+		}
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			engine.Group("/v" + strconv.Itoa(i)).Use(func(next HandleFunc) HandleFunc { return next })
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := performRequest(engine, http.MethodGet, "/api/ping")
+			if w.Code != http.StatusOK || w.Body.String() != "pong" {
+				errs <- fmt.Sprintf("/api/ping: got %d %q", w.Code, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	for i := 0; i < 100; i++ {
+		w := performRequest(engine, http.MethodGet, "/api/item"+strconv.Itoa(i)+"/7")
+		if w.Body.String() != "7" {
+			t.Errorf("/api/item%d/7: got %d %q", i, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRegisterInsideHandler(t *testing.T) {
+	engine := New()
+	g := engine.Group("/api")
+	g.GET("/register", func(ctx *Context) {
+		g.GET("/late", func(ctx *Context) {
+			ctx.String(http.StatusOK, "late")
+		})
+		ctx.String(http.StatusOK, "ok")
+	})
+	performRequest(engine, http.MethodGet, "/api/register")
+	if w := performRequest(engine, http.MethodGet, "/api/late"); w.Body.String() != "late" {
+		t.Errorf("/api/late: got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRouteConflictPanics(t *testing.T) {
+	tests := []struct {
+		register func(g *routerGroup)
+		msg      string
+	}{
+		{func(g *routerGroup) {
+			g.GET("/a/:id", func(ctx *Context) {})
+			g.GET("/a/:name", func(ctx *Context) {})
+		}, "vex: route conflict in group '/api': ':name' in route '/a/:name' conflicts with ':id' in the existing route '/a/:id'"},
+		{func(g *routerGroup) {
+			g.GET("/a", func(ctx *Context) {})
+			g.GET("/a", func(ctx *Context) {})
+		}, "vex: route 'GET /a' in group '/api' is already registered"},
+		{func(g *routerGroup) {
+			g.ANY("/a", func(ctx *Context) {})
+			g.POST("/a", func(ctx *Context) {})
+		}, "vex: route 'POST /a' in group '/api' is ambiguous with the ANY route of the same url"},
+		{func(g *routerGroup) {
+			g.GET("/b/:x<(>", func(ctx *Context) {})
+		}, "vex: invalid route in group '/api': invalid constraint '(': error parsing regexp: missing closing ): `^(?:()$` in route '/b/:x<(>'"},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if err := recover(); err != test.msg {
+					t.Errorf("panic = %v, want %s", err, test.msg)
+				}
+			}()
+			test.register(New().Group("/api"))
+		}()
+	}
+}
+
+func TestRouteConflictAcrossGroups(t *testing.T) {
+	tests := []struct {
+		register func(e *Engine)
+		msg      string
+		path     string // the path of the route registered first
+	}{
+		{func(e *Engine) {
+			e.Group("/api").GET("/a/:id", func(ctx *Context) {})
+			e.Group("/api").GET("/a/:name", func(ctx *Context) {})
+		}, "vex: route conflict in group '/api': ':name' in route '/api/a/:name' conflicts with ':id' in the existing route '/api/a/:id'", "/api/a/1"},
+		{func(e *Engine) {
+			e.Group("/api").GET("/a/:id", func(ctx *Context) {})
+			e.Group("/").GET("/api/a/:x", func(ctx *Context) {})
+		}, "vex: route conflict in group '/': ':x' in route '/api/a/:x' conflicts with ':id' in the existing route '/api/a/:id'", "/api/a/1"},
+		{func(e *Engine) {
+			e.Group("/").GET("/api/a", func(ctx *Context) {})
+			e.Group("/api").GET("/a", func(ctx *Context) {})
+		}, "vex: route 'GET /api/a' in group '/api' is already registered by another group", "/api/a"},
+		{func(e *Engine) {
+			e.Group("/api").ANY("/a", func(ctx *Context) {})
+			e.Group("/api").POST("/a", func(ctx *Context) {})
+		}, "vex: route 'POST /api/a' in group '/api' is ambiguous with the route of the same url in another group", "/api/a"},
+	}
+	for _, test := range tests {
+		engine := New()
+		func() {
+			defer func() {
+				if err := recover(); err != test.msg {
+					t.Errorf("panic = %v, want %s", err, test.msg)
+				}
+			}()
+			test.register(engine)
+		}()
+		if w := performRequest(engine, http.MethodGet, test.path); w.Code != http.StatusOK {
+			t.Errorf("%s: got %d after the conflict, want 200", test.path, w.Code)
+		}
+	}
+}
+
+func TestEmptyPathMatchesGroupPrefix(t *testing.T) {
+	engine := New()
+	route := engine.Group("/api").GET("", func(ctx *Context) {
+		ctx.String(http.StatusOK, "api")
+	})
+	engine.Group("/api").GET("/", func(ctx *Context) {
+		ctx.String(http.StatusOK, "api slash")
+	})
+	engine.Group("/").GET("", func(ctx *Context) {
+		ctx.String(http.StatusOK, "root")
+	})
+	engine.Group("/v1").GET("", func(ctx *Context) {
+		ctx.String(http.StatusOK, "v1")
+	})
+	if route.Path != "/api" {
+		t.Errorf("route path = %s, want /api", route.Path)
+	}
+	engine.RedirectCaseInsensitive = true
+
+	tests := []struct {
+		path     string
+		code     int
+		body     string
+		location string
+	}{
+		{"/api", http.StatusOK, "api", ""},
+		{"/api/", http.StatusOK, "api slash", ""},
+		{"/", http.StatusOK, "root", ""},
+		{"/v1", http.StatusOK, "v1", ""},
+		{"/v1/", http.StatusMovedPermanently, "", "/v1"},
+		{"/V1", http.StatusMovedPermanently, "", "/v1"},
+	}
+	for _, test := range tests {
+		w := performRequest(engine, http.MethodGet, test.path)
+		if w.Code != test.code || test.body != "" && w.Body.String() != test.body || w.Header().Get("Location") != test.location {
+			t.Errorf("%s: got %d %q %q, want %d %q %q", test.path, w.Code, w.Body.String(), w.Header().Get("Location"),
+				test.code, test.body, test.location)
+		}
+	}
+}
+
+func TestFailedRouteLeavesNoNode(t *testing.T) {
+	engine := New()
+	g := engine.Group("/api")
+	func() {
+		defer func() {
+			if err := recover(); err == nil {
+				t.Error("the invalid route should panic")
+			}
+		}()
+		g.GET("/b/*/c/:x<(>", func(ctx *Context) {})
+	}()
+	g.GET("/b/:y", func(ctx *Context) {
+		ctx.String(http.StatusOK, "b %s", ctx.Param("y"))
+	})
+	if w := performRequest(engine, http.MethodGet, "/api/b/1"); w.Body.String() != "b 1" {
+		t.Errorf("/api/b/1: got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestRouteConstraint(t *testing.T) {
+	engine := New()
+	engine.RegisterConstraint("upper", func(v string) bool { return v == strings.ToUpper(v) })
+	g := engine.Group("/api")
+	g.GET("/orders/:id<int>", func(ctx *Context) {
+		ctx.String(http.StatusOK, "order %s", ctx.Param("id"))
+	})
+	g.GET("/code/:c<upper>", func(ctx *Context) {
+		ctx.String(http.StatusOK, "code %s", ctx.Param("c"))
+	})
+	if w := performRequest(engine, http.MethodGet, "/api/orders/12"); w.Body.String() != "order 12" {
+		t.Errorf("/api/orders/12: got %d %q", w.Code, w.Body.String())
+	}
+	if w := performRequest(engine, http.MethodGet, "/api/orders/abc"); w.Code != http.StatusNotFound {
+		t.Errorf("/api/orders/abc: got %d, want 404", w.Code)
+	}
+	if w := performRequest(engine, http.MethodGet, "/api/code/VEX"); w.Body.String() != "code VEX" {
+		t.Errorf("/api/code/VEX: got %d %q", w.Code, w.Body.String())
+	}
+	if w := performRequest(engine, http.MethodGet, "/api/code/vex"); w.Code != http.StatusNotFound {
+		t.Errorf("/api/code/vex: got %d, want 404", w.Code)
+	}
+}
+
+func TestNestedGroup(t *testing.T) {
+	engine := New()
+	var order []string
+	record := func(name string) MiddlewareFunc {
+		return func(next HandleFunc) HandleFunc {
+			return func(ctx *Context) {
+				order = append(order, name)
+				next(ctx)
+			}
+		}
+	}
+	api := engine.Group("/api")
+	api.Use(record("api"))
+	v1 := api.Group("v1")
+	v1.Use(record("v1"))
+	admin := v1.Group("/admin")
+	admin.Use(record("admin"))
+	admin.GET("/users", func(ctx *Context) {
+		ctx.String(http.StatusOK, "admin users")
+	})
+	v1.GET("/users", func(ctx *Context) {
+		ctx.String(http.StatusOK, "v1 users")
+	})
+	engine.Group("/v1").GET("/users", func(ctx *Context) {
+		ctx.String(http.StatusOK, "top v1 users")
+	})
+
+	if admin.name != "/api/v1/admin" {
+		t.Errorf("admin group name = %s", admin.name)
+	}
+	tests := []struct {
+		path string
+		code int
+		body string
+	}{
+		{"/api/v1/admin/users", http.StatusOK, "admin users"},
+		{"/api/v1/users", http.StatusOK, "v1 users"},
+		{"/v1/users", http.StatusOK, "top v1 users"},
+		{"/api/v1x/users", http.StatusNotFound, ""},
+		{"/x/v1/users", http.StatusNotFound, ""},
+	}
+	for _, test := range tests {
+		w := performRequest(engine, http.MethodGet, test.path)
+		if w.Code != test.code || (test.body != "" && w.Body.String() != test.body) {
+			t.Errorf("%s: got %d %q, want %d %q", test.path, w.Code, w.Body.String(), test.code, test.body)
+		}
+	}
+
+	order = nil
+	performRequest(engine, http.MethodGet, "/api/v1/admin/users")
+	if strings.Join(order, ",") != "api,v1,admin" {
+		t.Errorf("middleware order = %v", order)
+	}
+}
+
+func TestHandlerChain(t *testing.T) {
+	engine := New()
+	var order []string
+	g := engine.Group("/api")
+	g.UseHandleFunc(func(ctx *Context) {
+		order = append(order, "first before")
+		ctx.Next()
+		order = append(order, "first after")
+	})
+	g.Use(func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			order = append(order, "adapted before")
+			next(ctx)
+			order = append(order, "adapted after")
+		}
+	})
+	g.UseHandleFunc(func(ctx *Context) {
+		order = append(order, "last")
+	})
+	g.GET("/chain", func(ctx *Context) {
+		order = append(order, "handler")
+		ctx.String(http.StatusOK, "ok")
+	})
+	performRequest(engine, http.MethodGet, "/api/chain")
+	want := "first before,adapted before,last,handler,adapted after,first after"
+	if strings.Join(order, ",") != want {
+		t.Errorf("order = %v, want %s", order, want)
+	}
+}
+
+func TestHandlerChainAbort(t *testing.T) {
+	engine := New()
+	called := false
+	g := engine.Group("/api")
+	g.Handle(http.MethodGet, "/json", func(ctx *Context) {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, map[string]string{"msg": "unauthorized"})
+	}, func(ctx *Context) {
+		called = true
+	})
+	// the adapted middleware does not call next, the rest of chain is aborted
+	g.GET("/adapted", func(ctx *Context) {
+		called = true
+	}, func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			ctx.String(http.StatusForbidden, "forbidden")
+		}
+	})
+	var aborted bool
+	g.Handle(http.MethodGet, "/status", func(ctx *Context) {
+		ctx.Next()
+		aborted = ctx.IsAborted()
+	}, func(ctx *Context) {
+		ctx.AbortWithStatus(http.StatusTeapot)
+	}, func(ctx *Context) {
+		called = true
+	})
+
+	w := performRequest(engine, http.MethodGet, "/api/json")
+	if w.Code != http.StatusUnauthorized || w.Body.String() != `{"msg":"unauthorized"}` {
+		t.Errorf("/api/json: got %d %q", w.Code, w.Body.String())
+	}
+	w = performRequest(engine, http.MethodGet, "/api/adapted")
+	if w.Code != http.StatusForbidden {
+		t.Errorf("/api/adapted: got %d", w.Code)
+	}
+	w = performRequest(engine, http.MethodGet, "/api/status")
+	if w.Code != http.StatusTeapot || !aborted {
+		t.Errorf("/api/status: got %d, aborted %v", w.Code, aborted)
+	}
+	if called {
+		t.Error("the handler after abort is called")
+	}
+}
+
+// benchmarkWriter is a ResponseWriter without any allocation
+type benchmarkWriter struct {
+	header http.Header
+}
+
+func (w *benchmarkWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *benchmarkWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *benchmarkWriter) WriteHeader(int) {}
+
+// benchmarkEngine register a route table like a REST api with group and route middlewares
+func benchmarkEngine() *Engine {
+	engine := New()
+	pass := func(ctx *Context) {
+		ctx.Next()
+	}
+	handler := func(ctx *Context) {}
+	api := engine.Group("/api")
+	api.UseHandleFunc(pass, pass)
+	v1 := api.Group("/v1")
+	v1.UseHandleFunc(pass)
+	for _, resource := range []string{"users", "orders", "goods", "shops", "comments", "tags", "files", "teams"} {
+		v1.Handle(http.MethodGet, "/"+resource, handler)
+		v1.Handle(http.MethodPost, "/"+resource, pass, handler)
+		v1.Handle(http.MethodGet, "/"+resource+"/:id", handler)
+		v1.Handle(http.MethodPut, "/"+resource+"/:id", pass, handler)
+		v1.Handle(http.MethodDelete, "/"+resource+"/:id", pass, handler)
+		v1.Handle(http.MethodGet, "/"+resource+"/:id/history/:version", handler)
+	}
+	admin := v1.Group("/admin")
+	admin.UseHandleFunc(pass)
+	admin.Handle(http.MethodGet, "/stats/**", handler)
+	return engine
+}
+
+func BenchmarkServeHTTP(b *testing.B) {
+	engine := benchmarkEngine()
+	requests := []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/v1/users", nil),
+		httptest.NewRequest(http.MethodPut, "/api/v1/orders/12", nil),
+		httptest.NewRequest(http.MethodGet, "/api/v1/teams/3/history/7", nil),
+		httptest.NewRequest(http.MethodGet, "/api/v1/admin/stats/daily/pv", nil),
+	}
+	w := &benchmarkWriter{header: make(http.Header)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		engine.ServeHTTP(w, requests[i%len(requests)])
+	}
+}
+
+func TestNextAfterAbort(t *testing.T) {
+	engine := New()
+	called := false
+	var aborted bool
+	engine.Group("/").Handle(http.MethodGet, "/", func(ctx *Context) {
+		ctx.Next()
+		// the handler after abort calls Next again
+		ctx.Next()
+		aborted = ctx.IsAborted()
+	}, func(ctx *Context) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		ctx.Next()
+	}, func(ctx *Context) {
+		called = true
+	})
+	w := performRequest(engine, http.MethodGet, "/")
+	if w.Code != http.StatusForbidden || called || !aborted {
+		t.Errorf("got %d, called %v, aborted %v", w.Code, called, aborted)
+	}
+
+	cp := (&Context{W: newResponseWriter(httptest.NewRecorder()), index: abortIndex}).Copy()
+	for i := 0; i < 3; i++ {
+		cp.Next()
+	}
+	if !cp.IsAborted() || cp.index > abortIndex+3 {
+		t.Errorf("index of copy is %d after Next, want aborted", cp.index)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the chain longer than abortIndex should panic")
+			}
+		}()
+		engine.Group("/long").Handle(http.MethodGet, "/", make([]HandleFunc, abortIndex)...)
+	}()
+	if w := performRequest(engine, http.MethodGet, "/long/"); w.Code != http.StatusNotFound {
+		t.Errorf("the route of too long chain is registered, got %d", w.Code)
+	}
+}
+
+func TestRecoveryAbortsChain(t *testing.T) {
+	engine := New()
+	engine.Logger.Outs = nil
+	engine.Use(Recovery)
+	g := engine.Group("/")
+	g.UseHandleFunc(func(ctx *Context) {
+		panic(errors.New("middleware panic"))
+	})
+	called := false
+	g.GET("/", func(ctx *Context) {
+		called = true
+		ctx.String(http.StatusOK, "handler ran")
+	})
+	w := performRequest(engine, http.MethodGet, "/")
+	if called {
+		t.Error("the handler is called after the middleware panics")
+	}
+	if w.Code != http.StatusInternalServerError || w.Body.String() != "Internal Server Error" {
+		t.Errorf("got %d %q, want 500 %q", w.Code, w.Body.String(), "Internal Server Error")
+	}
+}
+
+func TestEngineUseAfterGroup(t *testing.T) {
+	engine := New()
+	var order []string
+	record := func(name string) HandleFunc {
+		return func(ctx *Context) {
+			order = append(order, name)
+			ctx.Next()
+		}
+	}
+	engine.UseHandleFunc(record("engine1"))
+	g := engine.Group("/api")
+	g.UseHandleFunc(record("group"))
+	g.GET("/ping", func(ctx *Context) {
+		order = append(order, "handler")
+	})
+	// the engine middleware added after the group and route still applies
+	engine.UseHandleFunc(record("engine2"))
+	engine.Group("/late").GET("/ping", func(ctx *Context) {
+		order = append(order, "late handler")
+	})
+
+	performRequest(engine, http.MethodGet, "/api/ping")
+	if strings.Join(order, ",") != "engine1,engine2,group,handler" {
+		t.Errorf("order = %v", order)
+	}
+	order = nil
+	performRequest(engine, http.MethodGet, "/late/ping")
+	if strings.Join(order, ",") != "engine1,engine2,late handler" {
+		t.Errorf("order = %v", order)
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	engine := New()
+	engine.Group("/api").GET("/users", func(ctx *Context) {
+		ctx.String(http.StatusOK, "users")
+	})
+	engine.Group("/api/v1").POST("/users", func(ctx *Context) {})
+	// the same url in another group
+	engine.Group("/").DELETE("/api/users", func(ctx *Context) {})
+
+	w := performRequest(engine, http.MethodPut, "/api/users")
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT /api/users: got %d, want 405", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "DELETE, GET, HEAD, OPTIONS" {
+		t.Errorf("Allow = %q", allow)
+	}
+	if w := performRequest(engine, http.MethodDelete, "/api/users"); w.Code != http.StatusOK {
+		t.Errorf("DELETE /api/users: got %d, want 200", w.Code)
+	}
+
+	engine.HandleMethodNotAllowed = false
+	if w := performRequest(engine, http.MethodPut, "/api/users"); w.Code != http.StatusNotFound {
+		t.Errorf("PUT /api/users: got %d, want 404", w.Code)
+	}
+}
+
+func TestAutomaticHeadAndOptions(t *testing.T) {
+	engine := New()
+	g := engine.Group("/api")
+	g.GET("/users", func(ctx *Context) {
+		ctx.W.Header().Set("X-Total", "2")
+		ctx.String(http.StatusOK, "users")
+	})
+	g.POST("/users", func(ctx *Context) {})
+	g.GET("/custom", func(ctx *Context) {})
+	g.OPTION("/custom", func(ctx *Context) {
+		ctx.String(http.StatusOK, "custom options")
+	})
+
+	w := performRequest(engine, http.MethodHead, "/api/users")
+	if w.Code != http.StatusOK || w.Header().Get("X-Total") != "2" || w.Body.Len() != 0 {
+		t.Errorf("HEAD /api/users: got %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+	w = performRequest(engine, http.MethodOptions, "/api/users")
+	if w.Code != http.StatusNoContent || w.Header().Get("Allow") != "GET, HEAD, OPTIONS, POST" {
+		t.Errorf("OPTIONS /api/users: got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+	w = performRequest(engine, http.MethodOptions, "/api/custom")
+	if w.Body.String() != "custom options" {
+		t.Errorf("OPTIONS /api/custom: got %d %q", w.Code, w.Body.String())
+	}
+	if w := performRequest(engine, http.MethodOptions, "/api/none"); w.Code != http.StatusNotFound {
+		t.Errorf("OPTIONS /api/none: got %d, want 404", w.Code)
+	}
+
+	engine.HandleOPTIONS = false
+	if w := performRequest(engine, http.MethodOptions, "/api/users"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("OPTIONS /api/users: got %d, want 405", w.Code)
+	}
+}
+
+func TestNoRouteAndNoMethod(t *testing.T) {
+	engine := New()
+	var logged []int
+	engine.UseHandleFunc(func(ctx *Context) {
+		ctx.Next()
+		logged = append(logged, ctx.StatusCode)
+	})
+	engine.Group("/api").GET("/users", func(ctx *Context) {})
+
+	w := performRequest(engine, http.MethodGet, "/none")
+	if w.Code != http.StatusNotFound || w.Body.String() != "/none not found\n" {
+		t.Errorf("default NoRoute: got %d %q", w.Code, w.Body.String())
+	}
+
+	engine.NoRoute(func(ctx *Context) {
+		ctx.JSON(http.StatusNotFound, map[string]any{"code": 404, "msg": "not found"})
+	})
+	engine.NoMethod(func(ctx *Context) {
+		ctx.JSON(http.StatusMethodNotAllowed, map[string]any{"code": 405, "msg": ctx.W.Header().Get("Allow")})
+	})
+	w = performRequest(engine, http.MethodGet, "/none")
+	if w.Code != http.StatusNotFound || w.Body.String() != `{"code":404,"msg":"not found"}` {
+		t.Errorf("NoRoute: got %d %q", w.Code, w.Body.String())
+	}
+	w = performRequest(engine, http.MethodPost, "/api/users")
+	if w.Code != http.StatusMethodNotAllowed || w.Body.String() != `{"code":405,"msg":"GET, HEAD, OPTIONS"}` {
+		t.Errorf("NoMethod: got %d %q", w.Code, w.Body.String())
+	}
+	if fmt.Sprint(logged) != "[404 404 405]" {
+		t.Errorf("the middleware logged %v", logged)
+	}
+}
+
+func TestRedirectPath(t *testing.T) {
+	engine := New()
+	g := engine.Group("/api")
+	g.GET("/users", func(ctx *Context) {})
+	g.POST("/orders/", func(ctx *Context) {})
+	g.GET("/Files/:name", func(ctx *Context) {})
+
+	tests := []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{http.MethodGet, "/api/users/", http.StatusMovedPermanently, "/api/users"},
+		{http.MethodGet, "/api/users/?page=2", http.StatusMovedPermanently, "/api/users?page=2"},
+		{http.MethodPost, "/api/orders", http.StatusPermanentRedirect, "/api/orders/"},
+		{http.MethodGet, "/api//users", http.StatusMovedPermanently, "/api/users"},
+		{http.MethodGet, "/api/./orders/../users", http.StatusMovedPermanently, "/api/users"},
+		{http.MethodGet, "/API/USERS", http.StatusNotFound, ""},
+		{http.MethodPost, "/api/users/", http.StatusNotFound, ""},
+		{http.MethodGet, "/api/Files/a%3Fb=1/", http.StatusMovedPermanently, "/api/Files/a%3Fb=1"},
+		{http.MethodGet, "/api/Files/read%20me/?v=1", http.StatusMovedPermanently, "/api/Files/read%20me?v=1"},
+	}
+	check := func() {
+		for _, test := range tests {
+			w := performRequest(engine, test.method, test.path)
+			if w.Code != test.code || w.Header().Get("Location") != test.location {
+				t.Errorf("%s %s: got %d %q, want %d %q", test.method, test.path,
+					w.Code, w.Header().Get("Location"), test.code, test.location)
+			}
+		}
+	}
+	check()
+
+	engine.RedirectCaseInsensitive = true
+	tests = []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{http.MethodGet, "/API/USERS", http.StatusMovedPermanently, "/api/users"},
+		{http.MethodGet, "/Api/Users/", http.StatusMovedPermanently, "/api/users"},
+		{http.MethodGet, "/api/files/ReadMe.md", http.StatusMovedPermanently, "/api/Files/ReadMe.md"},
+	}
+	check()
+
+	engine.RedirectCaseInsensitive = false
+	engine.RedirectTrailingSlash = false
+	engine.RedirectCleanPath = false
+	tests = []struct {
+		method   string
+		path     string
+		code     int
+		location string
+	}{
+		{http.MethodGet, "/api/users/", http.StatusNotFound, ""},
+		{http.MethodGet, "/api//users", http.StatusNotFound, ""},
+	}
+	check()
+}
+
+func TestHostRoutingLiteralFirst(t *testing.T) {
+	engine := New()
+	// the param pattern is registered before the literal one
+	var tenant *HostRouter = engine.Host("{tenant}.example.com")
+	tenant.Group("/").GET("/", func(ctx *Context) {
+		ctx.String(http.StatusOK, "tenant %s", ctx.Param("tenant"))
+	})
+	engine.Host("api.example.com").Group("/").GET("/", func(ctx *Context) {
+		ctx.String(http.StatusOK, "api")
+	})
+
+	for host, body := range map[string]string{"api.example.com": "api", "foo.example.com": "tenant foo"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Host = host
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != http.StatusOK || w.Body.String() != body {
+			t.Errorf("%s: got %d %q, want 200 %q", host, w.Code, w.Body.String(), body)
+		}
+	}
+}
+
+func TestHostRouting(t *testing.T) {
+	engine := New()
+	tenant := engine.Host("{tenant}.example.com").Group("/api")
+	tenant.GET("/users/:id", func(ctx *Context) {
+		ctx.String(http.StatusOK, "tenant %s user %s", ctx.Param("tenant"), ctx.Param("id"))
+	})
+	tenant.POST("/orders", func(ctx *Context) {
+		ctx.String(http.StatusOK, "order")
+	})
+	engine.Host("Admin.Example.com").Group("/").GET("/", func(ctx *Context) {
+		ctx.String(http.StatusOK, "admin")
+	})
+	api := engine.Group("/api")
+	api.GET("/users/:id", func(ctx *Context) {
+		ctx.String(http.StatusOK, "default user %s", ctx.Param("id"))
+	})
+	api.GET("/health", func(ctx *Context) {
+		ctx.String(http.StatusOK, "ok")
+	})
+
+	if engine.Host("{tenant}.example.com") != engine.Host("{tenant}.example.com") {
+		t.Errorf("Host should return the same router for the same pattern")
+	}
+
+	tests := []struct {
+		method string
+		host   string
+		path   string
+		code   int
+		body   string
+	}{
+		{http.MethodGet, "acme.example.com", "/api/users/1", http.StatusOK, "tenant acme user 1"},
+		{http.MethodGet, "ACME.Example.COM:8080", "/api/users/2", http.StatusOK, "tenant ACME user 2"},
+		{http.MethodGet, "admin.example.com", "/", http.StatusOK, "admin"},
+		// the unmatched host and the url without route in host's router fall back to the engine's groups
+		{http.MethodGet, "example.com", "/api/users/1", http.StatusOK, "default user 1"},
+		{http.MethodGet, "a.b.example.com", "/api/users/1", http.StatusOK, "default user 1"},
+		{http.MethodGet, "acme.example.com", "/api/health", http.StatusOK, "ok"},
+		{http.MethodGet, "example.com", "/api/orders", http.StatusNotFound, ""},
+		{http.MethodGet, "acme.example.com", "/api/orders", http.StatusMethodNotAllowed, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Host = tt.host
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s %s%s: code = %d, want %d", tt.method, tt.host, tt.path, w.Code, tt.code)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s %s%s: body = %q, want %q", tt.method, tt.host, tt.path, w.Body.String(), tt.body)
+		}
+	}
+
+	var hosts []string
+	for _, route := range engine.Routes() {
+		hosts = append(hosts, route.Host+route.Path)
+	}
+	want := "/api/health,/api/users/:id,Admin.Example.com/,{tenant}.example.com/api/orders,{tenant}.example.com/api/users/:id"
+	if got := strings.Join(hosts, ","); got != want {
+		t.Errorf("Routes = %s, want %s", got, want)
+	}
+
+	for _, pattern := range []string{"", "a..com", "{}.com", "{a.com", "{a}.{a}.com", "x{a}.com"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Host(%q) should panic", pattern)
+				}
+			}()
+			engine.Host(pattern)
+		}()
+	}
+}
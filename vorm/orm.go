@@ -1,6 +1,7 @@
 package vorm
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -78,6 +79,16 @@ func (d *VexDb) SetConnMaxIdleTime(time time.Duration) {
 	d.db.SetConnMaxIdleTime(time)
 }
 
+// Ping 检查数据库连接是否有效, 可以作为 vex.HealthChecker 注册
+func (d *VexDb) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
+}
+
+// Close 关闭数据库连接, 可以在 vex.Engine 的 OnShutdown 中调用
+func (d *VexDb) Close() error {
+	return d.db.Close()
+}
+
 // New 创建 VexSession 使得数据操作在一个会话内
 func (d *VexDb) New(data any) *VexSession {
 	m := &VexSession{
@@ -0,0 +1,80 @@
+// Copyright 2022 Xue WenChao. All rights reserved.
+// Use of this source code is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package vex
+
+import (
+	"net/http"
+	"strings"
+)
+
+// WrapH turn the http.Handler into a HandleFunc, the handler writes to ctx.W and reads ctx.R
+//
+//	g.GET("/metrics", vex.WrapH(promhttp.Handler()))
+func WrapH(h http.Handler) HandleFunc {
+	return func(ctx *Context) {
+		h.ServeHTTP(ctx.W, ctx.R)
+	}
+}
+
+// WrapF turn the http.HandlerFunc into a HandleFunc
+func WrapF(f http.HandlerFunc) HandleFunc {
+	return func(ctx *Context) {
+		f(ctx.W, ctx.R)
+	}
+}
+
+// WrapMiddleware turn the net/http middleware into a MiddlewareFunc,
+// the rest of chain runs as the next http.Handler with the ResponseWriter and Request passed by the middleware,
+// they are set to ctx.W and ctx.R while the rest of chain is running.
+// if the middleware does not call the next handler the rest of chain is aborted
+//
+//	engine.Use(vex.WrapMiddleware(cors.Default().Handler))
+func WrapMiddleware(m func(http.Handler) http.Handler) MiddlewareFunc {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *Context) {
+			m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				originW, originR := ctx.W, ctx.R
+				ctx.W, ctx.R = newResponseWriter(w), r
+				next(ctx)
+				ctx.W, ctx.R = originW, originR
+			})).ServeHTTP(ctx.W, ctx.R)
+		}
+	}
+}
+
+// Mount serve all the methods of the prefix and the urls under it by the http.Handler,
+// the group's name and prefix are stripped from the url path before the handler is called like http.StripPrefix,
+// so the handler sees "/" for the prefix itself
+//
+//	engine.Group("/debug").Mount("/pprof", pprofMux) // /debug/pprof/heap ---> /heap
+func (r *routerGroup) Mount(prefix string, handler http.Handler) {
+	handleFunc := func(ctx *Context) {
+		rest := ctx.Param("**")
+		req := new(http.Request)
+		*req = *ctx.R
+		u := *ctx.R.URL
+		req.URL = &u
+		consumed := strings.TrimSuffix(strings.TrimSuffix(u.Path, rest), "/")
+		u.Path = "/" + rest
+		if u.RawPath != "" {
+			u.RawPath = skipSegments(u.RawPath, strings.Count(consumed, "/"))
+		}
+		handler.ServeHTTP(ctx.W, req)
+	}
+	r.ANY(prefix, handleFunc)
+	r.ANY(joinPaths(prefix, "/**"), handleFunc)
+}
+
+// skipSegments remove the first n segments of the escaped path, the rest starts with "/"
+func skipSegments(path string, n int) string {
+	for i := 0; i < n; i++ {
+		slash := strings.IndexByte(path[1:], '/')
+		if slash < 0 {
+			return "/"
+		}
+		path = path[slash+1:]
+	}
+	return path
+}
@@ -0,0 +1,99 @@
+package vex
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrapHandlers(t *testing.T) {
+	engine := New()
+	g := engine.Group("/")
+	g.GET("/h", WrapH(http.NotFoundHandler()))
+	g.GET("/f", WrapF(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "f %s", r.URL.Path)
+	}))
+
+	if w := performRequest(engine, http.MethodGet, "/h"); w.Code != http.StatusNotFound {
+		t.Errorf("WrapH code = %d, want %d", w.Code, http.StatusNotFound)
+	}
+	if w := performRequest(engine, http.MethodGet, "/f"); w.Body.String() != "f /f" {
+		t.Errorf("WrapF body = %q, want %q", w.Body.String(), "f /f")
+	}
+}
+
+type ctxKey struct{}
+
+func TestWrapMiddleware(t *testing.T) {
+	header := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Wrapped", "yes")
+			r = r.WithContext(context.WithValue(r.Context(), ctxKey{}, "1"))
+			next.ServeHTTP(w, r)
+		})
+	}
+	deny := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("token") == "" {
+				http.Error(w, "denied", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	engine := New()
+	engine.Use(WrapMiddleware(header))
+	g := engine.Group("/api")
+	g.Use(WrapMiddleware(deny))
+	called := 0
+	g.GET("/users", func(ctx *Context) {
+		called++
+		ctx.String(http.StatusOK, "users %s", ctx.R.Context().Value(ctxKey{}))
+	})
+
+	w := performRequest(engine, http.MethodGet, "/api/users?token=1")
+	if w.Code != http.StatusOK || w.Body.String() != "users 1" || w.Header().Get("X-Wrapped") != "yes" {
+		t.Errorf("got %d %q %q, want 200 \"users 1\" with X-Wrapped", w.Code, w.Body.String(), w.Header().Get("X-Wrapped"))
+	}
+	w = performRequest(engine, http.MethodGet, "/api/users")
+	if w.Code != http.StatusUnauthorized || called != 1 {
+		t.Errorf("got %d and handler called %d times, want 401 and 1", w.Code, called)
+	}
+}
+
+func TestMount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, r.URL.EscapedPath())
+	})
+	engine := New()
+	engine.Group("/debug").Mount("/pprof", mux)
+	engine.Group("/debug").GET("/vars", func(ctx *Context) {
+		ctx.String(http.StatusOK, "vars")
+	})
+
+	tests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodGet, "/debug/pprof", "GET / /"},
+		{http.MethodGet, "/debug/pprof/", "GET / /"},
+		{http.MethodPost, "/debug/pprof/heap", "POST /heap /heap"},
+		{http.MethodGet, "/debug/pprof/a%2Fb/c", "GET /a/b/c /a%2Fb/c"},
+		{http.MethodGet, "/debug/vars", "vars"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		if w.Body.String() != tt.body {
+			t.Errorf("%s %s: body = %q, want %q", tt.method, tt.path, w.Body.String(), tt.body)
+		}
+		if req.URL.Path == "/" {
+			t.Errorf("%s %s: the request of context is changed", tt.method, tt.path)
+		}
+	}
+}